@@ -0,0 +1,112 @@
+package migration
+
+import (
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// MigrationCache keeps parsed migrations between runs, so that the files of a migration are only read and
+// parsed once. Migrations are cached per source and per option affecting how their files are parsed, such
+// as the dialect, variables and template data, so runs with different sources or options do not share
+// migrations. Sources are identified by their address, so only the migrations of sources passed as pointers
+// are cached. The files of a source must not change while their migrations are cached. It is safe for
+// concurrent use.
+type MigrationCache struct {
+	mu         sync.Mutex
+	migrations map[string]*Migration
+
+	// sources are the sources of the cached migrations, identified by their index.
+	sources []Source
+}
+
+// NewMigrationCache creates an empty MigrationCache.
+func NewMigrationCache() *MigrationCache {
+	return &MigrationCache{
+		migrations: map[string]*Migration{},
+	}
+}
+
+// key identifies a migration by its source, the files defining it and the options they were parsed with.
+// Sources are identified by their address, so migrations are only cached for sources passed as pointers. Values
+// are not compared, as they may hold fields that cannot be compared, such as an fstest.MapFS. Migrations are not
+// cached either if variables are looked up by a function, or if template functions are used to render them, as
+// the results of functions may change between runs.
+func (c *MigrationCache) key(source Source, files []string, cfg *config) (string, bool) {
+	if c == nil || cfg.variableLookup != nil || reflect.ValueOf(source).Kind() != reflect.Ptr {
+		return "", false
+	}
+
+	if cfg.templateFuncs != nil {
+		for _, file := range files {
+			if strings.HasSuffix(file, templateExtension) {
+				return "", false
+			}
+		}
+	}
+
+	variables := make([]string, 0, len(cfg.variables))
+
+	for name, value := range cfg.variables {
+		variables = append(variables, name+"="+value)
+	}
+
+	sort.Strings(variables)
+
+	directives := append([]string{}, cfg.directives...)
+	sort.Strings(directives)
+
+	parts := []string{
+		fmt.Sprint(c.sourceIndex(source)),
+		string(cfg.dialect),
+		fmt.Sprint(cfg.stream),
+		strings.Join(variables, "\x01"),
+		strings.Join(directives, "\x01"),
+		fmt.Sprintf("%#v", cfg.templateData),
+	}
+
+	return strings.Join(append(parts, files...), "\x00"), true
+}
+
+// sourceIndex returns the index of the source, adding it to the sources of the cache if needed. The source
+// must be a pointer, so that comparing it compares addresses.
+func (c *MigrationCache) sourceIndex(source Source) int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for i, s := range c.sources {
+		if reflect.ValueOf(s).Pointer() == reflect.ValueOf(source).Pointer() && reflect.TypeOf(s) == reflect.TypeOf(source) {
+			return i
+		}
+	}
+
+	c.sources = append(c.sources, source)
+
+	return len(c.sources) - 1
+}
+
+func (c *MigrationCache) get(key string) (*Migration, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	migration, ok := c.migrations[key]
+
+	return migration, ok
+}
+
+func (c *MigrationCache) put(key string, migration *Migration) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.migrations[key] = migration
+}
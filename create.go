@@ -0,0 +1,119 @@
+package migration
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// NamingScheme defines how the numeric prefix of a new migration is chosen.
+type NamingScheme int
+
+// Constants for naming schemes
+const (
+	// Sequential uses the next number after the highest numeric prefix of the existing migrations.
+	Sequential NamingScheme = iota
+
+	// Timestamp uses the current UTC time formatted as YYYYMMDDHHMMSS.
+	Timestamp
+)
+
+var now = time.Now
+
+// Create creates an empty pair of up and down migration files in dir and returns their paths. The ID of
+// the migration is made up of a numeric prefix chosen according to the scheme, followed by the name,
+// with whitespace replaced by underscores.
+func Create(dir, name string, scheme NamingScheme) (up, down string, err error) {
+	name = strings.Join(strings.Fields(name), "_")
+
+	if name == "" {
+		return "", "", errors.New("the name of the migration must not be empty")
+	}
+
+	if strings.ContainsAny(name, `./\`) {
+		return "", "", fmt.Errorf("the name of the migration %q must not contain dots or path separators", name)
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", "", fmt.Errorf("error reading migrations directory: %w", err)
+	}
+
+	var (
+		last     int64
+		prefixes = map[int64]struct{}{}
+	)
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+
+		matches := migrationFilesRegex.FindStringSubmatch(entry.Name())
+
+		if len(matches) == 0 || entry.Name() != matches[0] {
+			if matches = singleMigrationFileRegex.FindStringSubmatch(entry.Name()); len(matches) == 0 {
+				continue
+			}
+		}
+
+		migration := Migration{ID: matches[1]}
+
+		if !migration.isNumeric() {
+			continue
+		}
+
+		prefixes[migration.VersionInt()] = struct{}{}
+
+		if migration.VersionInt() > last {
+			last = migration.VersionInt()
+		}
+	}
+
+	var prefix int64
+
+	switch scheme {
+	case Sequential:
+		prefix = last + 1
+	case Timestamp:
+		prefix, err = strconv.ParseInt(now().UTC().Format("20060102150405"), 10, 64)
+		if err != nil {
+			return "", "", err
+		}
+	default:
+		return "", "", fmt.Errorf("unknown naming scheme %d", scheme)
+	}
+
+	if _, ok := prefixes[prefix]; ok {
+		return "", "", fmt.Errorf("a migration with the prefix %d already exists", prefix)
+	}
+
+	id := fmt.Sprintf("%d_%s", prefix, name)
+
+	up = filepath.Join(dir, id+".up.sql")
+	down = filepath.Join(dir, id+".down.sql")
+
+	if err := createEmptyFile(up); err != nil {
+		return "", "", err
+	}
+
+	if err := createEmptyFile(down); err != nil {
+		_ = os.Remove(up)
+		return "", "", err
+	}
+
+	return up, down, nil
+}
+
+func createEmptyFile(path string) error {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return fmt.Errorf("error creating migration file: %w", err)
+	}
+
+	return file.Close()
+}
@@ -0,0 +1,69 @@
+package migration
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestCreate(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, file := range []string{"1_init.up.sql", "1_init.down.sql", "7_add_column.up.sql", "notes.txt"} {
+		if err := os.WriteFile(filepath.Join(dir, file), []byte(""), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	up, down, err := Create(dir, "add users table", Sequential)
+	if err != nil {
+		t.Fatalf("Unexpected error while creating migration: %s", err)
+	}
+
+	if up != filepath.Join(dir, "8_add_users_table.up.sql") || down != filepath.Join(dir, "8_add_users_table.down.sql") {
+		t.Errorf("Unexpected migration files %s and %s", up, down)
+	}
+
+	for _, file := range []string{up, down} {
+		if _, err := os.Stat(file); err != nil {
+			t.Errorf("Expected migration file %s to be created: %s", file, err)
+		}
+	}
+
+	for _, file := range []string{up, down} {
+		matches := migrationFilesRegex.FindStringSubmatch(filepath.Base(file))
+		if len(matches) == 0 || matches[1] != "8_add_users_table" {
+			t.Errorf("Expected migration file %s to be recognized as a migration", file)
+		}
+	}
+
+	defer func() {
+		now = time.Now
+	}()
+
+	now = func() time.Time {
+		return time.Date(2022, 10, 4, 14, 22, 5, 0, time.FixedZone("UTC+2", 2*60*60))
+	}
+
+	up, _, err = Create(dir, "add_index", Timestamp)
+	if err != nil {
+		t.Fatalf("Unexpected error while creating migration: %s", err)
+	}
+
+	if up != filepath.Join(dir, "20221004122205_add_index.up.sql") {
+		t.Errorf("Expected timestamp migration to use UTC, got %s", up)
+	}
+
+	if _, _, err = Create(dir, "add_other_index", Timestamp); err == nil {
+		t.Error("Expected error when creating a migration with an existing prefix, but there was no error")
+	}
+}
+
+func TestCreateWithInvalidName(t *testing.T) {
+	for _, name := range []string{"", "  ", "add.index", "sub/dir"} {
+		if _, _, err := Create(t.TempDir(), name, Sequential); err == nil {
+			t.Errorf("Expected error when creating a migration named %q, but there was no error", name)
+		}
+	}
+}
@@ -1,6 +1,8 @@
 // Package migration is a simple and pragmatic migration tool for Go.
 package migration
 
+import "context"
+
 // Driver is the interface type that needs to implemented by all drivers.
 type Driver interface {
 	// Close is the last function to be called.
@@ -15,3 +17,51 @@ type Driver interface {
 	// Version returns all applied migration versions
 	Versions() ([]string, error)
 }
+
+// ContextDriver is implemented by drivers that accept the context of the run. When a driver implements
+// ContextDriver, MigrateContext is called instead of Migrate.
+type ContextDriver interface {
+	Driver
+
+	// MigrateContext applies the PlannedMigration. Drivers should report the statements
+	// they execute using TraceStatement.
+	MigrateContext(ctx context.Context, migration *PlannedMigration) error
+}
+
+// DialectDriver is implemented by drivers for SQL databases. The dialect determines how the statements of
+// migrations marked with NoTransaction are split.
+type DialectDriver interface {
+	Driver
+
+	// Dialect returns the SQL dialect of the database.
+	Dialect() Dialect
+}
+
+// TransactionalDriver is implemented by drivers that can apply several migrations within a single
+// transaction. Atomic runs use it to roll back all migrations of the run when one of them fails.
+type TransactionalDriver interface {
+	Driver
+
+	// BeginTx starts a transaction in which the migrations of the run are applied.
+	BeginTx(ctx context.Context) (DriverTx, error)
+}
+
+// TransactionReporter is implemented by a TransactionalDriver whose use of transactions depends on how it was
+// created. If UsesTransactions returns false, atomic runs revert the migrations applied by the run when a
+// migration fails, as they do for drivers without transactions.
+type TransactionReporter interface {
+	// UsesTransactions returns true if the driver applies migrations within transactions.
+	UsesTransactions() bool
+}
+
+// DriverTx is a transaction started by a TransactionalDriver.
+type DriverTx interface {
+	// MigrateContext applies the PlannedMigration within the transaction.
+	MigrateContext(ctx context.Context, migration *PlannedMigration) error
+
+	// Commit commits the transaction.
+	Commit() error
+
+	// Rollback aborts the transaction.
+	Rollback() error
+}
@@ -46,6 +46,14 @@ func (g *Driver) Migrate(migration *m.PlannedMigration) error {
 
 	migrationFunc := g.source.GetMigration(file)
 
+	if migrationFunc == nil {
+		if g.source.GetTxMigration(file) != nil {
+			return fmt.Errorf("golang migration %s requires a transaction and must be run using a SQL driver", file)
+		}
+
+		return fmt.Errorf("golang migration %s does not exist", file)
+	}
+
 	err := migrationFunc()
 
 	if err != nil {
@@ -1,6 +1,8 @@
 package golang
 
 import (
+	"context"
+	"database/sql"
 	"testing"
 
 	"github.com/Boostport/migration"
@@ -132,3 +134,28 @@ func TestGolangDriver(t *testing.T) {
 		t.Error("Version was not deleted correctly")
 	}
 }
+
+func TestGolangDriverWithTxMigration(t *testing.T) {
+	source := migration.NewGolangMigrationSource()
+
+	source.AddTxMigration("1_init", migration.Up, func(ctx context.Context, tx *sql.Tx) error {
+		return nil
+	})
+
+	driver, err := New(source, func(id string, direction migration.Direction) error {
+		return nil
+	}, func() ([]string, error) {
+		return nil, nil
+	})
+	if err != nil {
+		t.Errorf("Unexpected error while creating driver: %s", err)
+	}
+
+	count, err := migration.Migrate(driver, source, migration.Up, 0)
+	if err == nil {
+		t.Error("Expected error while running a migration requiring a transaction, but there was no error")
+	}
+	if count != 0 {
+		t.Errorf("Expected %d migrations to be run, %d was actually run", 0, count)
+	}
+}
@@ -1,6 +1,7 @@
 package mysql
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -76,38 +77,76 @@ func (driver *Driver) ensureVersionTableExists() error {
 
 // Migrate runs a migration.
 func (driver *Driver) Migrate(migration *m.PlannedMigration) error {
+	return driver.MigrateContext(context.Background(), migration)
+}
+
+// MigrateContext runs a migration using the context of the run.
+func (driver *Driver) MigrateContext(ctx context.Context, migration *m.PlannedMigration) error {
 	// Note: Driver does not support DDL statements in a transaction. If DDL statements are
 	// executed in a transaction, it is an implicit commit.
 	// See: http://dev.mysql.com/doc/refman/5.7/en/implicit-commit.html
-	var migrationStatements *parser.ParsedMigration
+	var (
+		migrationStatements *parser.ParsedMigration
+		migrationFunc       m.TxMigrationFunc
+		updateVersion       string
+	)
 
 	if migration.Direction == m.Up {
 		migrationStatements = migration.Up
+		migrationFunc = migration.UpFunc
+		updateVersion = "INSERT INTO " + mysqlTableName + " (version) VALUES (?)"
 	} else if migration.Direction == m.Down {
 		migrationStatements = migration.Down
+		migrationFunc = migration.DownFunc
+		updateVersion = "DELETE FROM " + mysqlTableName + " WHERE version=?"
 	}
 
-	for _, sqlStmt := range migrationStatements.Statements {
+	if migrationFunc != nil {
+		return m.ExecTxMigration(ctx, driver.db, migration, migrationFunc, updateVersion)
+	}
+
+	err := migrationStatements.ForEachStatement(func(sqlStmt string, position parser.Position, options parser.StatementOptions) error {
 		if len(strings.TrimSpace(sqlStmt)) > 0 {
-			if _, err := driver.db.Exec(sqlStmt); err != nil {
-				return fmt.Errorf("Error executing statement: %s\n%s", err, sqlStmt)
-			}
+			return driver.execStatement(ctx, migration, sqlStmt, position, options)
 		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	if migration.Direction == m.Up {
-		if _, err := driver.db.Exec("INSERT INTO "+mysqlTableName+" (version) VALUES (?)", migration.ID); err != nil {
-			return err
-		}
-	} else {
-		if _, err := driver.db.Exec("DELETE FROM "+mysqlTableName+" WHERE version=?", migration.ID); err != nil {
-			return err
+	if _, err := driver.db.ExecContext(ctx, updateVersion, migration.ID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (driver *Driver) execStatement(ctx context.Context, migration *m.PlannedMigration, statement string, position parser.Position, options parser.StatementOptions) error {
+	err := m.ExecStatement(ctx, options, func(ctx context.Context) error {
+		ctx, end := m.TraceStatement(ctx, migration, statement)
+
+		result, err := driver.db.ExecContext(ctx, statement)
+		end(result, err)
+
+		return err
+	})
+
+	if err != nil {
+		if position.IsValid() {
+			return fmt.Errorf("Error executing statement at %s: %s\n%s", position, err, statement)
 		}
+		return fmt.Errorf("Error executing statement: %s\n%s", err, statement)
 	}
 
 	return nil
 }
 
+// Dialect returns the SQL dialect of MySQL.
+func (driver *Driver) Dialect() m.Dialect {
+	return m.MySQL
+}
+
 // Versions lists all the applied versions.
 func (driver *Driver) Versions() ([]string, error) {
 	var versions []string
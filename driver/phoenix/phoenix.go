@@ -1,6 +1,7 @@
 package phoenix
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -73,42 +74,78 @@ func (driver *Driver) ensureVersionTableExists() error {
 
 // Migrate runs a migration.
 func (driver *Driver) Migrate(migration *m.PlannedMigration) error {
+	return driver.MigrateContext(context.Background(), migration)
+}
+
+// MigrateContext runs a migration using the context of the run.
+func (driver *Driver) MigrateContext(ctx context.Context, migration *m.PlannedMigration) error {
 	// TODO: Driver does not support DDL statements in a transaction yet :( See PHOENIX-3358
-	var migrationStatements *parser.ParsedMigration
+	var (
+		migrationStatements *parser.ParsedMigration
+		migrationFunc       m.TxMigrationFunc
+		updateVersion       string
+	)
 
 	if migration.Direction == m.Up {
 		migrationStatements = migration.Up
+		migrationFunc = migration.UpFunc
+		updateVersion = "UPSERT INTO " + phoenixTableName + " (version) VALUES (?)"
 	} else if migration.Direction == m.Down {
 		migrationStatements = migration.Down
+		migrationFunc = migration.DownFunc
+		updateVersion = "DELETE FROM " + phoenixTableName + " WHERE version=?"
 	}
 
-	for _, sqlStmt := range migrationStatements.Statements {
+	if migrationFunc != nil {
+		return m.ExecTxMigration(ctx, driver.db, migration, migrationFunc, updateVersion)
+	}
+
+	err := migrationStatements.ForEachStatement(func(sqlStmt string, position parser.Position, options parser.StatementOptions) error {
 		// Special case for Phoenix. We force a statement split here, because Phoenix SQL statements must not be terminated with ;.
 		// In addition, this explicitly splits the SQL statements into its constituent statements.
-		splitted := strings.Split(sqlStmt, ";")
-
-		for _, content := range splitted {
-			if len(strings.TrimSpace(content)) > 0 {
-				if _, err := driver.db.Exec(content); err != nil {
-					return fmt.Errorf("Error executing statement: %s\n%s", err, content)
-				}
+		for _, content := range parser.SplitStatements(sqlStmt, parser.WithDialect(parser.Phoenix)) {
+			if err := driver.execStatement(ctx, migration, strings.TrimSuffix(content, ";"), position, options); err != nil {
+				return err
 			}
 		}
+		return nil
+	})
+	if err != nil {
+		return err
 	}
 
-	if migration.Direction == m.Up {
-		if _, err := driver.db.Exec("UPSERT INTO "+phoenixTableName+" (version) VALUES (?)", migration.ID); err != nil {
-			return err
-		}
-	} else {
-		if _, err := driver.db.Exec("DELETE FROM "+phoenixTableName+" WHERE version=?", migration.ID); err != nil {
-			return err
+	if _, err := driver.db.ExecContext(ctx, updateVersion, migration.ID); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (driver *Driver) execStatement(ctx context.Context, migration *m.PlannedMigration, statement string, position parser.Position, options parser.StatementOptions) error {
+	err := m.ExecStatement(ctx, options, func(ctx context.Context) error {
+		ctx, end := m.TraceStatement(ctx, migration, statement)
+
+		result, err := driver.db.ExecContext(ctx, statement)
+		end(result, err)
+
+		return err
+	})
+
+	if err != nil {
+		if position.IsValid() {
+			return fmt.Errorf("Error executing statement at %s: %s\n%s", position, err, statement)
 		}
+		return fmt.Errorf("Error executing statement: %s\n%s", err, statement)
 	}
 
 	return nil
 }
 
+// Dialect returns the SQL dialect of Phoenix.
+func (driver *Driver) Dialect() m.Dialect {
+	return m.Phoenix
+}
+
 // Versions lists all the applied versions.
 func (driver *Driver) Versions() ([]string, error) {
 	var versions []string
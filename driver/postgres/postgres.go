@@ -1,12 +1,16 @@
 package postgres
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
+	"strings"
+	"unicode"
 
 	m "github.com/Boostport/migration"
 	"github.com/Boostport/migration/parser"
+	"github.com/jackc/pgx/v5/pgconn"
 	"github.com/jackc/pgx/v5/stdlib"
 )
 
@@ -15,7 +19,12 @@ type Driver struct {
 	db *sql.DB
 }
 
-const postgresTableName = "schema_migration"
+const (
+	postgresTableName = "schema_migration"
+
+	// statementSavepoint is the savepoint of statements with options within a transaction.
+	statementSavepoint = "migration_statement"
+)
 
 // New creates a new Driver driver.
 // The DSN is documented here: https://pkg.go.dev/github.com/jackc/pgx/v4@v4.10.1/stdlib#pkg-overview
@@ -69,22 +78,18 @@ func (driver *Driver) ensureVersionTableExists() error {
 }
 
 // Migrate runs a migration.
-func (driver *Driver) Migrate(migration *m.PlannedMigration) (err error) {
-	var (
-		migrationStatements *parser.ParsedMigration
-		insertVersion       string
-	)
-
-	if migration.Direction == m.Up {
-		migrationStatements = migration.Up
-		insertVersion = "INSERT INTO " + postgresTableName + " (version) VALUES ($1)"
-	} else if migration.Direction == m.Down {
-		migrationStatements = migration.Down
-		insertVersion = "DELETE FROM " + postgresTableName + " WHERE version=$1"
-	}
-
-	if migrationStatements.UseTransaction {
-		tx, err := driver.db.Begin()
+func (driver *Driver) Migrate(migration *m.PlannedMigration) error {
+	return driver.MigrateContext(context.Background(), migration)
+}
+
+// MigrateContext runs a migration using the context of the run.
+func (driver *Driver) MigrateContext(ctx context.Context, migration *m.PlannedMigration) (err error) {
+	migrationStatements, migrationFunc, updateVersion := migrationParts(migration)
+
+	if migrationStatements.UseTransaction || migrationFunc != nil {
+		var tx *sql.Tx
+
+		tx, err = driver.db.BeginTx(ctx, nil)
 		if err != nil {
 			return err
 		}
@@ -99,26 +104,145 @@ func (driver *Driver) Migrate(migration *m.PlannedMigration) (err error) {
 			err = tx.Commit()
 		}()
 
-		for _, statement := range migrationStatements.Statements {
-			if _, err = tx.Exec(statement); err != nil {
-				return fmt.Errorf("error executing statement: %s\n%s", err, statement)
-			}
+		return migrateTx(ctx, tx, migration)
+	}
+
+	err = migrationStatements.ForEachStatement(func(statement string, position parser.Position, options parser.StatementOptions) error {
+		return execStatement(ctx, driver.db, migration, statement, position, options)
+	})
+	if err != nil {
+		return err
+	}
+	if _, err = driver.db.ExecContext(ctx, updateVersion, migration.ID); err != nil {
+		return fmt.Errorf("error updating migration versions: %s", err)
+	}
+
+	return
+}
+
+// BeginTx starts a transaction for applying all migrations of an atomic run.
+func (driver *Driver) BeginTx(ctx context.Context) (m.DriverTx, error) {
+	tx, err := driver.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tx{
+		tx: tx,
+	}, nil
+}
+
+// Tx applies the migrations of an atomic run within a single transaction.
+type Tx struct {
+	tx *sql.Tx
+}
+
+// MigrateContext runs a migration within the transaction.
+func (t *Tx) MigrateContext(ctx context.Context, migration *m.PlannedMigration) error {
+	if migrationStatements, _, _ := migrationParts(migration); !migrationStatements.UseTransaction {
+		return fmt.Errorf("migration %s is marked with NoTransaction and cannot be applied in an atomic run", migration.ID)
+	}
+
+	return migrateTx(ctx, t.tx, migration)
+}
+
+// Commit commits the transaction.
+func (t *Tx) Commit() error {
+	return t.tx.Commit()
+}
+
+// Rollback aborts the transaction.
+func (t *Tx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+// migrationParts returns the statements, Go function and version update of the migration in its direction.
+func migrationParts(migration *m.PlannedMigration) (*parser.ParsedMigration, m.TxMigrationFunc, string) {
+	if migration.Direction == m.Down {
+		return migration.Down, migration.DownFunc, "DELETE FROM " + postgresTableName + " WHERE version=$1"
+	}
+
+	return migration.Up, migration.UpFunc, "INSERT INTO " + postgresTableName + " (version) VALUES ($1)"
+}
+
+func migrateTx(ctx context.Context, tx *sql.Tx, migration *m.PlannedMigration) error {
+	migrationStatements, migrationFunc, updateVersion := migrationParts(migration)
+
+	err := migrationStatements.ForEachStatement(func(statement string, position parser.Position, options parser.StatementOptions) error {
+		return execStatement(ctx, tx, migration, statement, position, options)
+	})
+	if err != nil {
+		return err
+	}
+
+	if migrationFunc != nil {
+		if err := migrationFunc(ctx, tx); err != nil {
+			return fmt.Errorf("error executing golang migration: %s", err)
 		}
+	}
+
+	if _, err := tx.ExecContext(ctx, updateVersion, migration.ID); err != nil {
+		return fmt.Errorf("error updating migration versions: %s", err)
+	}
+
+	return nil
+}
 
-		if _, err = tx.Exec(insertVersion, migration.ID); err != nil {
-			return fmt.Errorf("error updating migration versions: %s", err)
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func execStatement(ctx context.Context, db execer, migration *m.PlannedMigration, statement string, position parser.Position, options parser.StatementOptions) error {
+	// A failing statement aborts the transaction it is executed in. Statements whose errors are ignored or
+	// retried are executed within a savepoint, which is rolled back if they fail.
+	_, inTx := db.(*sql.Tx)
+	useSavepoint := inTx && options.HandlesErrors()
+
+	err := m.ExecStatement(ctx, options, func(ctx context.Context) error {
+		if useSavepoint {
+			if _, err := db.ExecContext(ctx, "SAVEPOINT "+statementSavepoint); err != nil {
+				return err
+			}
 		}
-	} else {
-		for _, statement := range migrationStatements.Statements {
-			if _, err := driver.db.Exec(statement); err != nil {
-				return fmt.Errorf("error executing statement: %s\n%s", err, statement)
+
+		ctx, end := m.TraceStatement(ctx, migration, statement)
+
+		result, err := db.ExecContext(ctx, statement)
+		end(result, err)
+
+		if useSavepoint {
+			savepointStatement := "RELEASE SAVEPOINT " + statementSavepoint
+			if err != nil {
+				savepointStatement = "ROLLBACK TO SAVEPOINT " + statementSavepoint
+			}
+
+			if _, errSp := db.ExecContext(ctx, savepointStatement); errSp != nil && err == nil {
+				return errSp
 			}
 		}
-		if _, err = driver.db.Exec(insertVersion, migration.ID); err != nil {
-			return fmt.Errorf("error updating migration versions: %s", err)
+
+		return err
+	})
+
+	if err != nil {
+		var pgErr *pgconn.PgError
+
+		if errors.As(err, &pgErr) && pgErr.Position > 0 && position.IsValid() {
+			position.Line = errorLine(statement, position.Line, int(pgErr.Position))
 		}
+
+		if position.IsValid() {
+			return fmt.Errorf("error executing statement at %s: %s\n%s", position, err, statement)
+		}
+		return fmt.Errorf("error executing statement: %s\n%s", err, statement)
 	}
-	return
+
+	return nil
+}
+
+// Dialect returns the SQL dialect of Postgres.
+func (driver *Driver) Dialect() m.Dialect {
+	return m.Postgres
 }
 
 // Versions lists all the applied versions.
@@ -146,3 +270,17 @@ func (driver *Driver) Versions() ([]string, error) {
 
 	return versions, err
 }
+
+// errorLine returns the line of the character at the 1-based position reported by Postgres for an error in
+// a statement whose first non-whitespace character is on the given line.
+func errorLine(statement string, line, position int) int {
+	runes := []rune(statement)
+
+	if position > len(runes) {
+		return line
+	}
+
+	leading := len(statement) - len(strings.TrimLeftFunc(statement, unicode.IsSpace))
+
+	return line - strings.Count(statement[:leading], "\n") + strings.Count(string(runes[:position-1]), "\n")
+}
@@ -4,6 +4,7 @@ import (
 	"database/sql"
 	"errors"
 	"os"
+	"strings"
 	"testing"
 
 	"github.com/Boostport/migration"
@@ -170,6 +171,54 @@ func TestPostgresDriver(t *testing.T) {
 	}
 }
 
+func TestPostgresDriverWithStatementOptions(t *testing.T) {
+	postgresHost := os.Getenv("POSTGRES_HOST")
+	database := "migrationtest"
+
+	// prepare clean database
+	connection, err := sql.Open("pgx", "postgres://postgres:@"+postgresHost+"/?sslmode=disable")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		err := connection.Close()
+		if err != nil {
+			t.Errorf("unexpected error while closing the postgres connection: %v", err)
+		}
+	}()
+
+	_, err = connection.Exec("CREATE DATABASE " + database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_, err := connection.Exec("DROP DATABASE IF EXISTS " + database)
+		if err != nil {
+			t.Errorf("unexpected error while dropping the postgres database %s: %v", database, err)
+		}
+	}()
+
+	driver, err := New("postgres://postgres:@" + postgresHost + "/" + database + "?sslmode=disable")
+	if err != nil {
+		t.Fatalf("unable to open connection to postgres server: %s", err)
+	}
+
+	migrations := &migration.MemoryMigrationSource{
+		Files: map[string]string{
+			"1_init.up.sql": "CREATE TABLE test_table1 (id integer not null primary key);\n-- +migration IgnoreError: does not exist\nDROP INDEX legacy_index;\nINSERT INTO test_table1 (id) VALUES (1);\n",
+		},
+	}
+
+	// The ignored error must not abort the transaction of the migration
+	applied, err := migration.Migrate(driver, migrations, migration.Up, 0)
+	if err != nil {
+		t.Errorf("unexpected error while running migration with an ignored error: %s", err)
+	}
+	if applied != 1 {
+		t.Errorf("expected %d migrations to be applied, %d was actually applied.", 1, applied)
+	}
+}
+
 func TestCreateDriverUsingInvalidDBInstance(t *testing.T) {
 	db, _, err := sqlmock.New()
 	if err != nil {
@@ -227,3 +276,18 @@ func TestCreateDriverUsingDBInstance(t *testing.T) {
 		}
 	}()
 }
+
+func TestErrorLine(t *testing.T) {
+	statement := "\n\nCREATE TABLE test_table1 (\n    id intgr not null primary key\n);"
+
+	// Postgres reports the position of "intgr", counted in characters from the start of the statement.
+	position := strings.Index(statement, "intgr") + 1
+
+	if line := errorLine(statement, 10, position); line != 11 {
+		t.Errorf("Expected error to be on line %d, got %d", 11, line)
+	}
+
+	if line := errorLine(statement, 10, len(statement)+1); line != 10 {
+		t.Errorf("Expected line of the statement for a position outside of the statement, got %d", line)
+	}
+}
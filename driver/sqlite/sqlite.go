@@ -1,6 +1,7 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
 	"errors"
 	"fmt"
@@ -44,8 +45,20 @@ func New(dsn string, useTransactions bool) (m.Driver, error) {
 	return d, nil
 }
 
-// NewFromDB returns a sqlite driver from a sql.db
-func NewFromDB(db *sql.DB) (m.Driver, error) {
+// Option configures a driver created by NewFromDB.
+type Option func(*Driver)
+
+// WithTransactions applies each migration within a transaction, like New with useTransactions set to true.
+// Atomic runs then apply all migrations of the run within a single transaction.
+func WithTransactions() Option {
+	return func(d *Driver) {
+		d.useTransactions = true
+	}
+}
+
+// NewFromDB returns a sqlite driver from a sql.db. Migrations are not applied within transactions, unless the
+// WithTransactions option is passed.
+func NewFromDB(db *sql.DB, opts ...Option) (m.Driver, error) {
 	if _, ok := db.Driver().(*sqlite.Driver); !ok {
 		return nil, errors.New("database instance is not using the postgres driver")
 	}
@@ -58,6 +71,10 @@ func NewFromDB(db *sql.DB) (m.Driver, error) {
 		db: db,
 	}
 
+	for _, opt := range opts {
+		opt(d)
+	}
+
 	if err := d.ensureVersionTableExists(); err != nil {
 		return nil, err
 	}
@@ -77,23 +94,18 @@ func (driver *Driver) ensureVersionTableExists() error {
 }
 
 // Migrate runs a migration.
-func (driver *Driver) Migrate(migration *m.PlannedMigration) (err error) {
-	var (
-		migrationStatements *parser.ParsedMigration
-		insertVersion       string
-	)
+func (driver *Driver) Migrate(migration *m.PlannedMigration) error {
+	return driver.MigrateContext(context.Background(), migration)
+}
 
-	if migration.Direction == m.Up {
-		migrationStatements = migration.Up
-		insertVersion = "INSERT INTO " + sqliteTableName + " (version) VALUES (?)"
+// MigrateContext runs a migration using the context of the run.
+func (driver *Driver) MigrateContext(ctx context.Context, migration *m.PlannedMigration) (err error) {
+	migrationStatements, migrationFunc, updateVersion := migrationParts(migration)
 
-	} else if migration.Direction == m.Down {
-		migrationStatements = migration.Down
-		insertVersion = "DELETE FROM " + sqliteTableName + " WHERE version=?"
-	}
+	if driver.useTransactions || migrationFunc != nil {
+		var tx *sql.Tx
 
-	if driver.useTransactions {
-		tx, err := driver.db.Begin()
+		tx, err = driver.db.BeginTx(ctx, nil)
 		if err != nil {
 			return err
 		}
@@ -108,27 +120,123 @@ func (driver *Driver) Migrate(migration *m.PlannedMigration) (err error) {
 			err = tx.Commit()
 		}()
 
-		for _, statement := range migrationStatements.Statements {
-			if _, err = tx.Exec(statement); err != nil {
-				return fmt.Errorf("error executing statement: %s\n%s", err, statement)
-			}
-		}
+		return migrateTx(ctx, tx, migration)
+	}
 
-		if _, err = tx.Exec(insertVersion, migration.ID); err != nil {
-			return fmt.Errorf("error updating migration versions: %s", err)
-		}
-	} else {
-		for _, statement := range migrationStatements.Statements {
-			if _, err := driver.db.Exec(statement); err != nil {
-				return fmt.Errorf("error executing statement: %s\n%s", err, statement)
-			}
+	err = migrationStatements.ForEachStatement(func(statement string, position parser.Position, options parser.StatementOptions) error {
+		return execStatement(ctx, driver.db, migration, statement, position, options)
+	})
+	if err != nil {
+		return err
+	}
+	if _, err = driver.db.ExecContext(ctx, updateVersion, migration.ID); err != nil {
+		return fmt.Errorf("error updating migration versions: %s", err)
+	}
+
+	return
+}
+
+// UsesTransactions returns true if the driver was created with transactions. Atomic runs of drivers without
+// transactions revert the migrations applied by the run when a migration fails.
+func (driver *Driver) UsesTransactions() bool {
+	return driver.useTransactions
+}
+
+// BeginTx starts a transaction for applying all migrations of an atomic run.
+func (driver *Driver) BeginTx(ctx context.Context) (m.DriverTx, error) {
+	tx, err := driver.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tx{
+		tx: tx,
+	}, nil
+}
+
+// Tx applies the migrations of an atomic run within a single transaction.
+type Tx struct {
+	tx *sql.Tx
+}
+
+// MigrateContext runs a migration within the transaction.
+func (t *Tx) MigrateContext(ctx context.Context, migration *m.PlannedMigration) error {
+	if migrationStatements, _, _ := migrationParts(migration); !migrationStatements.UseTransaction {
+		return fmt.Errorf("migration %s is marked with NoTransaction and cannot be applied in an atomic run", migration.ID)
+	}
+
+	return migrateTx(ctx, t.tx, migration)
+}
+
+// Commit commits the transaction.
+func (t *Tx) Commit() error {
+	return t.tx.Commit()
+}
+
+// Rollback aborts the transaction.
+func (t *Tx) Rollback() error {
+	return t.tx.Rollback()
+}
+
+// migrationParts returns the statements, Go function and version update of the migration in its direction.
+func migrationParts(migration *m.PlannedMigration) (*parser.ParsedMigration, m.TxMigrationFunc, string) {
+	if migration.Direction == m.Down {
+		return migration.Down, migration.DownFunc, "DELETE FROM " + sqliteTableName + " WHERE version=?"
+	}
+
+	return migration.Up, migration.UpFunc, "INSERT INTO " + sqliteTableName + " (version) VALUES (?)"
+}
+
+func migrateTx(ctx context.Context, tx *sql.Tx, migration *m.PlannedMigration) error {
+	migrationStatements, migrationFunc, updateVersion := migrationParts(migration)
+
+	err := migrationStatements.ForEachStatement(func(statement string, position parser.Position, options parser.StatementOptions) error {
+		return execStatement(ctx, tx, migration, statement, position, options)
+	})
+	if err != nil {
+		return err
+	}
+
+	if migrationFunc != nil {
+		if err := migrationFunc(ctx, tx); err != nil {
+			return fmt.Errorf("error executing golang migration: %s", err)
 		}
-		if _, err = driver.db.Exec(insertVersion, migration.ID); err != nil {
-			return fmt.Errorf("error updating migration versions: %s", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, updateVersion, migration.ID); err != nil {
+		return fmt.Errorf("error updating migration versions: %s", err)
+	}
+
+	return nil
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+}
+
+func execStatement(ctx context.Context, db execer, migration *m.PlannedMigration, statement string, position parser.Position, options parser.StatementOptions) error {
+	err := m.ExecStatement(ctx, options, func(ctx context.Context) error {
+		ctx, end := m.TraceStatement(ctx, migration, statement)
+
+		result, err := db.ExecContext(ctx, statement)
+		end(result, err)
+
+		return err
+	})
+
+	if err != nil {
+		if position.IsValid() {
+			return fmt.Errorf("error executing statement at %s: %s\n%s", position, err, statement)
 		}
+		return fmt.Errorf("error executing statement: %s\n%s", err, statement)
 	}
 
-	return
+	return nil
+}
+
+// Dialect returns the SQL dialect of SQLite.
+func (driver *Driver) Dialect() m.Dialect {
+	return m.SQLite
 }
 
 // Versions lists all the applied versions.
@@ -1,8 +1,13 @@
 package sqlite
 
 import (
+	"context"
 	"database/sql"
+	"errors"
+	"path/filepath"
 	"regexp"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/Boostport/migration"
@@ -136,6 +141,288 @@ func TestSQLiteDriver(t *testing.T) {
 	}
 }
 
+func TestSQLiteDriverWithTxMigrations(t *testing.T) {
+	for _, useTransactions := range []bool{true, false} {
+		driver, err := New("file:txmigrations?mode=memory&cache=shared", useTransactions)
+		if err != nil {
+			t.Fatalf("unable to open connection to server: %s", err)
+		}
+
+		source := migration.NewGolangMigrationSource()
+
+		source.AddTxMigration("1_init", migration.Up, func(ctx context.Context, tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, "CREATE TABLE test_table1 (id integer not null primary key)"); err != nil {
+				return err
+			}
+
+			_, err := tx.ExecContext(ctx, "INSERT INTO test_table1 (id) VALUES (1)")
+			return err
+		})
+
+		source.AddTxMigration("2_failing_update", migration.Up, func(ctx context.Context, tx *sql.Tx) error {
+			if _, err := tx.ExecContext(ctx, "INSERT INTO test_table1 (id) VALUES (2)"); err != nil {
+				return err
+			}
+
+			return errors.New("failing migration")
+		})
+
+		applied, err := migration.Migrate(driver, source, migration.Up, 0)
+		if err == nil {
+			t.Error("expected an error while running a failing migration, but did not receive any.")
+		}
+		if applied != 1 {
+			t.Errorf("expected %d migrations to be applied, %d was actually applied.", 1, applied)
+		}
+
+		var count int
+
+		if err := driver.(*Driver).db.QueryRow("SELECT COUNT(*) FROM test_table1").Scan(&count); err != nil {
+			t.Errorf("unexpected error while counting rows: %s", err)
+		}
+		if count != 1 {
+			t.Errorf("expected changes of the failing migration to be rolled back, found %d rows", count)
+		}
+
+		versions, err := driver.Versions()
+		if err != nil {
+			t.Errorf("unexpected error while retriving version information: %s", err)
+		}
+		if len(versions) != 1 || versions[0] != "1_init" {
+			t.Errorf("expected only 1_init to be applied, got %v", versions)
+		}
+
+		for _, table := range []string{"test_table1", sqliteTableName} {
+			if _, err := driver.(*Driver).db.Exec("DROP TABLE " + table); err != nil {
+				t.Errorf("unexpected error %v while droping the table: %s", err, table)
+			}
+		}
+
+		err = driver.Close()
+		if err != nil {
+			t.Errorf("unexpected error %v while closing the sqlite driver", err)
+		}
+	}
+}
+
+func TestSQLiteDriverWithMixedMigrations(t *testing.T) {
+	// Keep the in-memory database alive after the driver is closed by Migrate.
+	db, err := sql.Open("sqlite", "file:mixedmigrations?mode=memory&cache=shared")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer func() {
+		if err := db.Close(); err != nil {
+			t.Errorf("unexpected error %v while closing the sqlite database", err)
+		}
+	}()
+
+	if err := db.Ping(); err != nil {
+		t.Fatal(err)
+	}
+
+	driver, err := New("file:mixedmigrations?mode=memory&cache=shared", true)
+	if err != nil {
+		t.Fatalf("unable to open connection to server: %s", err)
+	}
+
+	goMigrations := migration.NewGolangMigrationSource()
+
+	goMigrations.AddTxMigration("8_backfill", migration.Up, func(ctx context.Context, tx *sql.Tx) error {
+		_, err := tx.ExecContext(ctx, "UPDATE test_table1 SET name = 'backfilled'")
+		return err
+	})
+
+	source := migration.NewCompositeMigrationSource(
+		&migration.MemoryMigrationSource{
+			Files: map[string]string{
+				"7_add_col.up.sql":  "CREATE TABLE test_table1 (id integer not null primary key, name text); INSERT INTO test_table1 (id) VALUES (1);",
+				"9_drop_col.up.sql": "CREATE TABLE test_table2 AS SELECT name FROM test_table1; DROP TABLE test_table1;",
+			},
+		},
+		goMigrations,
+	)
+
+	applied, err := migration.Migrate(driver, source, migration.Up, 0)
+	if err != nil {
+		t.Errorf("unexpected error while running migrations: %s", err)
+	}
+	if applied != 3 {
+		t.Errorf("expected %d migrations to be applied, %d was actually applied.", 3, applied)
+	}
+
+	var name string
+
+	if err := db.QueryRow("SELECT name FROM test_table2").Scan(&name); err != nil {
+		t.Errorf("unexpected error while querying migrated table: %s", err)
+	}
+	if name != "backfilled" {
+		t.Errorf("expected Go migration to run between the SQL migrations, got name %q", name)
+	}
+
+	var versions int
+
+	if err := db.QueryRow("SELECT COUNT(*) FROM " + sqliteTableName).Scan(&versions); err != nil {
+		t.Errorf("unexpected error while counting versions: %s", err)
+	}
+	if versions != 3 {
+		t.Errorf("expected %d versions to be recorded, got %d", 3, versions)
+	}
+}
+
+func TestSQLiteDriverWithAtomicRun(t *testing.T) {
+	driver, err := New("file:atomicrun?mode=memory&cache=shared", true)
+	if err != nil {
+		t.Fatalf("unable to open connection to server: %s", err)
+	}
+
+	migrations := &migration.MemoryMigrationSource{
+		Files: map[string]string{
+			"1_init.up.sql":             "CREATE TABLE test_table1 (id integer not null primary key);",
+			"1_init.down.sql":           "DROP TABLE test_table1;",
+			"2_failing_update.up.sql":   "INSERT INTO test_table1 (id) VALUES (1);\nINSERT INTO missing_table (id) VALUES (1);",
+			"2_failing_update.down.sql": "DELETE FROM test_table1;",
+		},
+	}
+
+	applied, err := migration.Migrate(driver, migrations, migration.Up, 0, migration.WithAtomicRun())
+	if err == nil {
+		t.Error("expected an error while running a failing migration, but did not receive any.")
+	}
+	if applied != 0 {
+		t.Errorf("expected %d migrations to be applied, %d was actually applied.", 0, applied)
+	}
+
+	var count int
+
+	if err := driver.(*Driver).db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE type='table' AND name='test_table1'").Scan(&count); err != nil {
+		t.Errorf("unexpected error while checking for table: %s", err)
+	}
+	if count != 0 {
+		t.Error("expected the table created by the first migration to be rolled back")
+	}
+
+	versions, err := driver.Versions()
+	if err != nil {
+		t.Errorf("unexpected error while retriving version information: %s", err)
+	}
+	if len(versions) != 0 {
+		t.Errorf("expected no migrations to be applied, got %v", versions)
+	}
+
+	err = driver.Close()
+	if err != nil {
+		t.Errorf("unexpected error %v while closing the sqlite driver", err)
+	}
+}
+
+func TestSQLiteDriverErrorPosition(t *testing.T) {
+	driver, err := New("file:errorposition?mode=memory&cache=shared", false)
+	if err != nil {
+		t.Fatalf("unable to open connection to server: %s", err)
+	}
+
+	migrations := &migration.MemoryMigrationSource{
+		Files: map[string]string{
+			"1_init.up.sql": "-- +migration NoTransaction\n\nCREATE TABLE test_table1 (id integer not null primary key);\nINSERT INTO missing_table (id) VALUES (1);\n",
+		},
+	}
+
+	_, err = migration.Migrate(driver, migrations, migration.Up, 0)
+	if err == nil {
+		t.Fatal("expected an error while running a failing migration, but did not receive any.")
+	}
+	if !strings.Contains(err.Error(), "1_init.up.sql:4") {
+		t.Errorf("expected error to contain the position of the failing statement, got %q", err)
+	}
+
+	err = driver.Close()
+	if err != nil {
+		t.Errorf("unexpected error %v while closing the sqlite driver", err)
+	}
+}
+
+func TestSQLiteDriverWithStatementOptions(t *testing.T) {
+	driver, err := New("file:statementoptions?mode=memory&cache=shared", true)
+	if err != nil {
+		t.Fatalf("unable to open connection to server: %s", err)
+	}
+
+	migrations := &migration.MemoryMigrationSource{
+		Files: map[string]string{
+			"1_init.up.sql": "CREATE TABLE test_table1 (id integer not null primary key);\n-- +migration IgnoreError: no such index\nDROP INDEX legacy_index;\nINSERT INTO test_table1 (id) VALUES (1);\n",
+			"2_fail.up.sql": "-- +migration IgnoreError: no such index\nDROP TABLE missing_table;\n",
+		},
+	}
+
+	applied, err := migration.Migrate(driver, migrations, migration.Up, 0)
+	if err == nil {
+		t.Fatal("expected an error for a statement failing with an error that is not ignored, but did not receive any.")
+	}
+	if applied != 1 {
+		t.Errorf("expected %d migrations to be applied, %d was actually applied.", 1, applied)
+	}
+	if !strings.Contains(err.Error(), "2_fail.up.sql:2") {
+		t.Errorf("expected error to contain the position of the failing statement, got %q", err)
+	}
+}
+
+func TestSQLiteDriverWithStreaming(t *testing.T) {
+	dsn := "file:" + filepath.Join(t.TempDir(), "streaming.db")
+
+	var inserts strings.Builder
+
+	for i := 1; i <= 1000; i++ {
+		inserts.WriteString("INSERT INTO test_table1 (id, name) VALUES (" + strconv.Itoa(i) + ", 'row;" + strconv.Itoa(i) + "');\n")
+	}
+
+	migrations := &migration.MemoryMigrationSource{
+		Files: map[string]string{
+			"1_init.up.sql":   "CREATE TABLE test_table1 (id integer not null primary key, name text);",
+			"1_init.down.sql": "DROP TABLE test_table1;",
+			"2_data.up.sql":   inserts.String(),
+			"2_data.down.sql": "DELETE FROM test_table1;",
+			"3_fail.up.sql":   "-- +migration NoTransaction\nUPDATE test_table1 SET name = 'updated';\n\nINSERT INTO missing_table (id) VALUES (1);\n",
+		},
+	}
+
+	driver, err := New(dsn, true)
+	if err != nil {
+		t.Fatalf("unable to open connection to server: %s", err)
+	}
+
+	applied, err := migration.Migrate(driver, migrations, migration.Up, 2, migration.WithStreaming())
+	if err != nil {
+		t.Fatalf("unexpected error while running migrations: %s", err)
+	}
+	if applied != 2 {
+		t.Errorf("expected %d migrations to be applied, %d was actually applied.", 2, applied)
+	}
+
+	driver, err = New(dsn, true)
+	if err != nil {
+		t.Fatalf("unable to open connection to server: %s", err)
+	}
+
+	var count int
+
+	if err := driver.(*Driver).db.QueryRow("SELECT COUNT(*) FROM test_table1").Scan(&count); err != nil {
+		t.Errorf("unexpected error while counting rows: %s", err)
+	}
+	if count != 1000 {
+		t.Errorf("expected %d rows to be inserted, got %d", 1000, count)
+	}
+
+	_, err = migration.Migrate(driver, migrations, migration.Up, 0, migration.WithStreaming())
+	if err == nil {
+		t.Fatal("expected an error while running a failing migration, but did not receive any.")
+	}
+	if !strings.Contains(err.Error(), "3_fail.up.sql:4") {
+		t.Errorf("expected error to contain the position of the failing statement, got %q", err)
+	}
+}
+
 func TestCreateDriverUsingInvalidDBInstance(t *testing.T) {
 	db, _, err := sqlmock.New()
 	if err != nil {
@@ -166,3 +453,100 @@ func TestCreateDriverUsingDBInstance(t *testing.T) {
 		}
 	}()
 }
+
+func TestSQLiteDriverAtomicRunWithoutTransactions(t *testing.T) {
+	migrations := &migration.MemoryMigrationSource{
+		Files: map[string]string{
+			"1_init.up.sql":   "CREATE TABLE test_table1 (id integer not null primary key);",
+			"1_init.down.sql": "DROP TABLE test_table1;",
+			"2_fail.up.sql":   "INSERT INTO missing_table (id) VALUES (1);",
+			"2_fail.down.sql": "SELECT 1;",
+		},
+	}
+
+	driver, err := New("file:atomicrunwithouttransactions?mode=memory&cache=shared", false)
+	if err != nil {
+		t.Fatalf("unable to open connection to server: %s", err)
+	}
+
+	applied, err := migration.Migrate(driver, migrations, migration.Up, 0, migration.WithAtomicRun())
+	if err == nil || !strings.Contains(err.Error(), "all migrations of the run were reverted") {
+		t.Errorf("expected the applied migrations to be reverted, got %v", err)
+	}
+	if applied != 0 {
+		t.Errorf("expected %d migrations to be applied, %d was actually applied.", 0, applied)
+	}
+
+	if err := driver.Close(); err != nil {
+		t.Errorf("unexpected error %v while closing the sqlite driver", err)
+	}
+
+	driver, err = New("file:atomicrunnotransaction?mode=memory&cache=shared", true)
+	if err != nil {
+		t.Fatalf("unable to open connection to server: %s", err)
+	}
+
+	migrations.Files["1_init.up.sql"] = "-- +migration NoTransaction\nCREATE TABLE test_table1 (id integer not null primary key);"
+
+	_, err = migration.Migrate(driver, migrations, migration.Up, 0, migration.WithAtomicRun())
+	if err == nil || !strings.Contains(err.Error(), "marked with NoTransaction") {
+		t.Errorf("expected an error for a NoTransaction migration in an atomic run, got %v", err)
+	}
+
+	if err := driver.Close(); err != nil {
+		t.Errorf("unexpected error %v while closing the sqlite driver", err)
+	}
+}
+
+func TestSQLiteDriverFromDBAtomicRun(t *testing.T) {
+	migrations := &migration.MemoryMigrationSource{
+		Files: map[string]string{
+			"1_init.up.sql":   "CREATE TABLE test_table1 (id integer not null primary key);",
+			"1_init.down.sql": "DROP TABLE test_table1;",
+			"2_fail.up.sql":   "INSERT INTO missing_table (id) VALUES (1);",
+			"2_fail.down.sql": "SELECT 1;",
+		},
+	}
+
+	testCases := []struct {
+		opts     []Option
+		expected string
+	}{
+		{nil, "all migrations of the run were reverted"},
+		{[]Option{WithTransactions()}, "all migrations of the run were rolled back"},
+	}
+
+	for i, testCase := range testCases {
+		db, err := sql.Open("sqlite", "file:"+filepath.Join(t.TempDir(), "fromdb.db"))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		driver, err := NewFromDB(db, testCase.opts...)
+		if err != nil {
+			t.Fatalf("unable to create SQLite driver: %s", err)
+		}
+
+		if usesTransactions := driver.(*Driver).UsesTransactions(); usesTransactions != (testCase.opts != nil) {
+			t.Errorf("expected driver %d to use transactions: %t, got %t", i, testCase.opts != nil, usesTransactions)
+		}
+
+		_, err = migration.Migrate(driver, migrations, migration.Up, 0, migration.WithAtomicRun())
+		if err == nil || !strings.Contains(err.Error(), testCase.expected) {
+			t.Errorf("expected an error containing %q for driver %d, got %v", testCase.expected, i, err)
+		}
+
+		var count int
+
+		if err := db.QueryRow("SELECT COUNT(*) FROM sqlite_master WHERE name = 'test_table1'").Scan(&count); err != nil {
+			t.Errorf("unexpected error while checking the table: %s", err)
+		}
+		if count != 0 {
+			t.Errorf("expected the run of driver %d to leave no table behind", i)
+		}
+
+		if err := db.Close(); err != nil {
+			t.Errorf("unexpected error %v while closing the database", err)
+		}
+	}
+}
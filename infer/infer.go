@@ -0,0 +1,218 @@
+// Package infer proposes down migrations for up migrations containing simple DDL statements.
+package infer
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/Boostport/migration/parser"
+)
+
+const name = "(" + parser.Name + ")"
+
+// Option configures how down migrations are inferred.
+type Option func(*config)
+
+type config struct {
+	dialect parser.Dialect
+}
+
+// WithDialect sets the dialect of the migration. It is used for splitting statements and for inverting
+// statements whose inverse depends on the dialect, such as CREATE INDEX, which are not inverted if the
+// dialect is not set.
+func WithDialect(dialect parser.Dialect) Option {
+	return func(c *config) {
+		c.dialect = dialect
+	}
+}
+
+// rule inverts statements matching its regex. Inverse receives the matches of the regex and the dialect
+// of the migration, and returns an empty string if the matched statement cannot be inverted.
+type rule struct {
+	regex   *regexp.Regexp
+	inverse func(matches []string, dialect parser.Dialect) string
+}
+
+var rules = []rule{
+	{
+		regex: regexp.MustCompile(`(?is)^CREATE\s+(?:(?:GLOBAL\s+|LOCAL\s+)?(?:TEMP|TEMPORARY)\s+)?TABLE\s+(IF\s+NOT\s+EXISTS\s+)?` + name + `\s*\(`),
+		inverse: func(matches []string, _ parser.Dialect) string {
+			return "DROP TABLE " + ifExists(matches[1]) + matches[2] + ";"
+		},
+	},
+	{
+		regex: regexp.MustCompile(`(?is)^CREATE\s+(UNIQUE\s+)?INDEX\s+(CONCURRENTLY\s+)?(IF\s+NOT\s+EXISTS\s+)?` + name + `\s+ON\s+(?:ONLY\s+)?` + name),
+		inverse: func(matches []string, dialect parser.Dialect) string {
+			concurrently := ""
+
+			if matches[2] != "" {
+				concurrently = "CONCURRENTLY "
+			}
+
+			switch dialect {
+			case parser.Postgres, parser.SQLite:
+				return "DROP INDEX " + concurrently + ifExists(matches[3]) + matches[4] + ";"
+			case parser.MySQL, parser.Phoenix:
+				// Indexes belong to their table
+				return "DROP INDEX " + ifExists(matches[3]) + unqualified(matches[4]) + " ON " + matches[5] + ";"
+			default:
+				return ""
+			}
+		},
+	},
+	{
+		regex: regexp.MustCompile(`(?is)^CREATE\s+(MATERIALIZED\s+)?VIEW\s+(IF\s+NOT\s+EXISTS\s+)?` + name + `\s`),
+		inverse: func(matches []string, dialect parser.Dialect) string {
+			materialized := ""
+
+			if matches[1] != "" {
+				materialized = "MATERIALIZED "
+			}
+
+			return "DROP " + materialized + "VIEW " + ifExists(matches[2]) + matches[3] + ";"
+		},
+	},
+	{
+		regex: regexp.MustCompile(`(?is)^CREATE\s+(SEQUENCE|SCHEMA|TYPE|DOMAIN|EXTENSION)\s+(IF\s+NOT\s+EXISTS\s+)?` + name + `(?:\s|;|$)`),
+		inverse: func(matches []string, dialect parser.Dialect) string {
+			return "DROP " + strings.ToUpper(matches[1]) + " " + ifExists(matches[2]) + matches[3] + ";"
+		},
+	},
+	{
+		regex: regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(?:ONLY\s+)?` + name + `\s+ADD\s+CONSTRAINT\s+` + name + `\s+([^,]*?);?$`),
+		inverse: func(matches []string, dialect parser.Dialect) string {
+			return "ALTER TABLE " + matches[1] + " DROP CONSTRAINT " + matches[2] + ";"
+		},
+	},
+	{
+		regex: regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(?:ONLY\s+)?` + name + `\s+ADD\s+(?:COLUMN\s+)?(IF\s+NOT\s+EXISTS\s+)?` + name + `\s+([^,]*?);?$`),
+		inverse: func(matches []string, dialect parser.Dialect) string {
+			switch strings.ToUpper(matches[3]) {
+			case "PRIMARY", "UNIQUE", "FOREIGN", "CHECK", "INDEX", "KEY":
+				return ""
+			}
+
+			return "ALTER TABLE " + matches[1] + " DROP COLUMN " + ifExists(matches[2]) + matches[3] + ";"
+		},
+	},
+	{
+		regex: regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+` + name + `\s+RENAME\s+TO\s+` + name + `\s*;?$`),
+		inverse: func(matches []string, dialect parser.Dialect) string {
+			// The new name is in the schema of the table
+			return "ALTER TABLE " + qualified(matches[2], matches[1]) + " RENAME TO " + unqualified(matches[1]) + ";"
+		},
+	},
+	{
+		regex: regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+` + name + `\s+RENAME\s+(?:COLUMN\s+)?` + name + `\s+TO\s+` + name + `\s*;?$`),
+		inverse: func(matches []string, dialect parser.Dialect) string {
+			return "ALTER TABLE " + matches[1] + " RENAME COLUMN " + matches[3] + " TO " + matches[2] + ";"
+		},
+	},
+}
+
+// Down reads an up migration and returns a proposed down migration. The statements of the up migration
+// are inverted in reverse order. Statements that cannot be inverted are marked with a
+// "-- +migration TODO" directive, so that the down migration is refused by the parser until
+// the TODO has been replaced by a human.
+func Down(r io.Reader, opts ...Option) (string, error) {
+	cfg := &config{}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	up, err := parser.Parse(r, parser.WithDialect(cfg.dialect))
+	if err != nil {
+		return "", err
+	}
+
+	var statements []string
+
+	for _, chunk := range up.Statements {
+		chunk = parser.StripComments(chunk)
+
+		if chunk == "" {
+			continue
+		}
+
+		if parser.IsProcedural(chunk) {
+			statements = append(statements, chunk)
+			continue
+		}
+
+		for _, statement := range parser.SplitStatements(chunk, parser.WithDialect(cfg.dialect)) {
+			if statement = parser.StripComments(statement); statement != "" {
+				statements = append(statements, statement)
+			}
+		}
+	}
+
+	var b strings.Builder
+
+	if !up.UseTransaction {
+		b.WriteString("-- +migration NoTransaction\n")
+	}
+
+	for i := len(statements) - 1; i >= 0; i-- {
+		if i < len(statements)-1 {
+			b.WriteString("\n")
+		}
+
+		b.WriteString(invert(statements[i], cfg.dialect))
+		b.WriteString("\n")
+	}
+
+	return b.String(), nil
+}
+
+func invert(statement string, dialect parser.Dialect) string {
+	if !parser.IsProcedural(statement) {
+		for _, r := range rules {
+			if matches := r.regex.FindStringSubmatch(statement); matches != nil {
+				if inverse := r.inverse(matches, dialect); inverse != "" {
+					return inverse
+				}
+				break
+			}
+		}
+	}
+
+	lines := strings.Split(statement, "\n")
+
+	for i, line := range lines {
+		lines[i] = "-- " + line
+	}
+
+	return fmt.Sprintf("-- +migration TODO: cannot infer the inverse of the following statement\n%s", strings.Join(lines, "\n"))
+}
+
+func ifExists(ifNotExists string) string {
+	if ifNotExists != "" {
+		return "IF EXISTS "
+	}
+
+	return ""
+}
+
+// qualified returns the name in the schema of other, unless the name already has a schema.
+func qualified(name, other string) string {
+	if strings.Contains(name, ".") {
+		return name
+	}
+
+	if i := strings.LastIndex(other, "."); i >= 0 {
+		return other[:i+1] + name
+	}
+
+	return name
+}
+
+func unqualified(name string) string {
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		return name[i+1:]
+	}
+
+	return name
+}
@@ -0,0 +1,98 @@
+package infer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/Boostport/migration/parser"
+)
+
+func TestDown(t *testing.T) {
+	testMigrations := []struct {
+		up      string
+		dialect parser.Dialect
+		down    string
+	}{
+		{
+			up: `CREATE TABLE test_table1 (id integer not null primary key);
+				CREATE TABLE IF NOT EXISTS public.test_table2 (id integer not null primary key);`,
+			down: "DROP TABLE IF EXISTS public.test_table2;\n\nDROP TABLE test_table1;\n",
+		},
+		{
+			up: `ALTER TABLE test_table1 ADD COLUMN name varchar(255) NOT NULL DEFAULT '';
+				ALTER TABLE test_table1 ADD last_name text;
+				ALTER TABLE test_table1 ADD CONSTRAINT name_unique UNIQUE (name);`,
+			down: "ALTER TABLE test_table1 DROP CONSTRAINT name_unique;\n\nALTER TABLE test_table1 DROP COLUMN last_name;\n\nALTER TABLE test_table1 DROP COLUMN name;\n",
+		},
+		{
+			up: `-- +migration NoTransaction
+				CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS idx_name ON test_table1 (name);`,
+			dialect: parser.Postgres,
+			down:    "-- +migration NoTransaction\nDROP INDEX CONCURRENTLY IF EXISTS idx_name;\n",
+		},
+		{
+			up:      "CREATE INDEX idx_name ON test_table1 (name);",
+			dialect: parser.MySQL,
+			down:    "DROP INDEX idx_name ON test_table1;\n",
+		},
+		{
+			up:   "CREATE INDEX idx_name ON test_table1 (name);",
+			down: "-- +migration TODO: cannot infer the inverse of the following statement\n-- CREATE INDEX idx_name ON test_table1 (name);\n",
+		},
+		{
+			up:   "ALTER TABLE app.test_table1 RENAME TO test_table2;",
+			down: "ALTER TABLE app.test_table2 RENAME TO test_table1;\n",
+		},
+		{
+			up: `CREATE VIEW test_view AS SELECT id FROM test_table1;
+				CREATE SEQUENCE test_sequence;
+				ALTER TABLE test_table1 RENAME TO test_table3;
+				ALTER TABLE test_table3 RENAME COLUMN name TO full_name;`,
+			down: "ALTER TABLE test_table3 RENAME COLUMN full_name TO name;\n\nALTER TABLE test_table3 RENAME TO test_table1;\n\nDROP SEQUENCE test_sequence;\n\nDROP VIEW test_view;\n",
+		},
+		{
+			up: `-- Backfill names
+				UPDATE test_table1
+				SET name = 'unknown';`,
+			down: "-- +migration TODO: cannot infer the inverse of the following statement\n-- UPDATE test_table1\n-- \t\t\t\tSET name = 'unknown';\n",
+		},
+	}
+
+	for i, testCase := range testMigrations {
+		down, err := Down(strings.NewReader(testCase.up), WithDialect(testCase.dialect))
+		if err != nil {
+			t.Errorf("Unexpected error while inferring down migration for test case %d: %s", i, err)
+		}
+		if down != testCase.down {
+			t.Errorf("Inferred down migration for test case %d did not match expected result, got:\n%s", i, down)
+		}
+	}
+}
+
+func TestDownWithTODOIsRefused(t *testing.T) {
+	up := `CREATE TABLE test_table1 (id integer not null primary key);
+
+	-- +migration BeginStatement
+	CREATE TRIGGER test_trigger BEFORE UPDATE ON test_table1 FOR EACH ROW BEGIN
+		INSERT INTO test_table2 SET id = OLD.id;
+	END
+	-- +migration EndStatement
+	`
+
+	down, err := Down(strings.NewReader(up))
+	if err != nil {
+		t.Fatalf("Unexpected error while inferring down migration: %s", err)
+	}
+
+	if !strings.HasPrefix(down, "-- +migration TODO") || !strings.Contains(down, "-- \t\tINSERT INTO test_table2 SET id = OLD.id;") {
+		t.Errorf("Expected trigger to be marked with a TODO as a whole, got:\n%s", down)
+	}
+
+	if !strings.HasSuffix(down, "DROP TABLE test_table1;\n") {
+		t.Errorf("Expected table to be dropped, got:\n%s", down)
+	}
+
+	if _, err := parser.Parse(strings.NewReader(down)); err == nil {
+		t.Error("Expected inferred down migration with a TODO to be refused by the parser, but there was no error")
+	}
+}
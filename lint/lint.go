@@ -0,0 +1,434 @@
+// Package lint reports dangerous patterns in migrations, such as statements dropping data or breaking
+// running versions of an application, so that they can be reviewed before they reach production.
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Boostport/migration"
+	"github.com/Boostport/migration/parser"
+)
+
+// Severity is the severity of an issue.
+type Severity int
+
+// Constants for severities
+const (
+	Info Severity = iota
+	Warning
+	Error
+)
+
+// String returns a string representation of the severity
+func (s Severity) String() string {
+	switch s {
+	case Info:
+		return "info"
+	case Warning:
+		return "warning"
+	case Error:
+		return "error"
+	default:
+		return "unknown"
+	}
+}
+
+// Rule reports statements matching a dangerous pattern.
+type Rule struct {
+	// Name identifies the rule in issues and Allow directives.
+	Name string
+
+	// Message describes the danger of the statements reported by the rule.
+	Message string
+
+	Severity Severity
+
+	// Dialects are the dialects the rule applies to. Rules without dialects apply to all dialects.
+	Dialects []migration.Dialect
+
+	// Check returns true if the statement should be reported. The statement does not contain
+	// leading comments or surrounding whitespace.
+	Check func(statement string) bool
+}
+
+func (r *Rule) appliesTo(dialect migration.Dialect) bool {
+	if len(r.Dialects) == 0 {
+		return true
+	}
+
+	for _, d := range r.Dialects {
+		if d == dialect {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Issue is a statement reported by a rule.
+type Issue struct {
+	Rule      string
+	Severity  Severity
+	Message   string
+	Migration string
+	Position  parser.Position
+	Statement string
+}
+
+// String returns the issue formatted as position: severity: rule: message.
+func (i Issue) String() string {
+	location := i.Migration
+
+	if i.Position.IsValid() {
+		location = i.Position.String()
+	}
+
+	return fmt.Sprintf("%s: %s: %s: %s", location, i.Severity, i.Rule, i.Message)
+}
+
+// unknownRule reports Allow directives naming rules that do not exist, which would otherwise silently
+// stop suppressing issues when a rule is renamed.
+const unknownRule = "unknown-rule"
+
+const identifier = parser.Identifier
+
+const name = parser.Name
+
+var (
+	alterTableRegex    = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?` + name + `\s+(.*)$`)
+	dropColumnRegex    = regexp.MustCompile(`(?is)^DROP\s+(?:COLUMN\s+)?(?:IF\s+EXISTS\s+)?(` + identifier + `)`)
+	addColumnRegex     = regexp.MustCompile(`(?is)^ADD\s+(?:COLUMN\s+)?(?:IF\s+NOT\s+EXISTS\s+)?(` + identifier + `)\s`)
+	notNullRegex       = regexp.MustCompile(`(?is)\bNOT\s+NULL\b`)
+	defaultRegex       = regexp.MustCompile(`(?is)\bDEFAULT\b|\bGENERATED\b|\bAUTO_INCREMENT\b`)
+	whereRegex         = regexp.MustCompile(`(?is)\bWHERE\b`)
+	renameTableRegex   = regexp.MustCompile(`(?is)^(?:ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?` + name + `\s+RENAME\s+TO\s|RENAME\s+TABLE\s)`)
+	renameColumnRegex  = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+(?:IF\s+EXISTS\s+)?(?:ONLY\s+)?` + name + `\s+RENAME\s+(?:COLUMN\s+)?` + identifier + `\s+TO\s`)
+	changeColumnRegex  = regexp.MustCompile(`(?is)^CHANGE\s+(?:COLUMN\s+)?` + identifier + `\s`)
+	blockOpenRegex     = regexp.MustCompile(`(?i)\bBEGIN\b|\bCASE\b`)
+	blockCloseRegex    = regexp.MustCompile(`(?i)\bEND\b(?:\s+(IF|LOOP|WHILE|REPEAT|CASE)\b)?`)
+	updateRegex        = regexp.MustCompile(`(?is)^UPDATE\s`)
+	deleteRegex        = regexp.MustCompile(`(?is)^DELETE\s+FROM\s`)
+	dropTableRegex     = regexp.MustCompile(`(?is)^DROP\s+TABLE\s`)
+	constraintKeywords = map[string]bool{
+		"CONSTRAINT": true,
+		"INDEX":      true,
+		"KEY":        true,
+		"PRIMARY":    true,
+		"FOREIGN":    true,
+		"UNIQUE":     true,
+		"CHECK":      true,
+		"DEFAULT":    true,
+		"PARTITION":  true,
+	}
+)
+
+// DefaultRules are the rules used by Lint if no rules are given.
+var DefaultRules = []Rule{
+	{
+		Name:     "drop-table",
+		Message:  "dropping a table deletes its data and breaks running versions of the application using it",
+		Severity: Error,
+		Check:    dropTableRegex.MatchString,
+	},
+	{
+		Name:     "drop-column",
+		Message:  "dropping a column deletes its data and breaks running versions of the application using it",
+		Severity: Error,
+		Check: func(statement string) bool {
+			return anyAlterTableClause(statement, func(clause string) bool {
+				matches := dropColumnRegex.FindStringSubmatch(clause)
+				return matches != nil && !constraintKeywords[strings.ToUpper(matches[1])]
+			})
+		},
+	},
+	{
+		Name:     "add-not-null-column-without-default",
+		Message:  "adding a NOT NULL column without a default fails for tables containing rows and breaks inserts of running versions of the application",
+		Severity: Error,
+		Check: func(statement string) bool {
+			return anyAlterTableClause(statement, func(clause string) bool {
+				matches := addColumnRegex.FindStringSubmatch(clause)
+				return matches != nil && !constraintKeywords[strings.ToUpper(matches[1])] && notNullRegex.MatchString(clause) && !defaultRegex.MatchString(clause)
+			})
+		},
+	},
+	{
+		Name:     "update-without-where",
+		Message:  "updating without a WHERE clause changes every row of the table",
+		Severity: Error,
+		Check: func(statement string) bool {
+			return updateRegex.MatchString(statement) && !whereRegex.MatchString(statement)
+		},
+	},
+	{
+		Name:     "delete-without-where",
+		Message:  "deleting without a WHERE clause deletes every row of the table",
+		Severity: Error,
+		Check: func(statement string) bool {
+			return deleteRegex.MatchString(statement) && !whereRegex.MatchString(statement)
+		},
+	},
+	{
+		Name:     "rename-table",
+		Message:  "renaming a table breaks running versions of the application using the old name",
+		Severity: Warning,
+		Check:    renameTableRegex.MatchString,
+	},
+	{
+		Name:     "rename-column",
+		Message:  "renaming a column breaks running versions of the application using the old name",
+		Severity: Warning,
+		Check:    renameColumnRegex.MatchString,
+	},
+	{
+		Name:     "change-column",
+		Message:  "CHANGE COLUMN can rename the column, which breaks running versions of the application using the old name",
+		Severity: Warning,
+		Dialects: []migration.Dialect{migration.MySQL},
+		Check: func(statement string) bool {
+			return anyAlterTableClause(statement, changeColumnRegex.MatchString)
+		},
+	},
+}
+
+// Lint reports the statements of the up migrations in the source matching the rules for the dialect. If rules
+// is nil, DefaultRules are used. The options are used for parsing the migrations, as they would be by Migrate.
+func Lint(source migration.Source, dialect migration.Dialect, rules []Rule, opts ...migration.Option) ([]Issue, error) {
+	migrations, err := migration.Migrations(source, dialect, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []Issue
+
+	for _, m := range migrations {
+		if m.Up == nil {
+			continue
+		}
+
+		migrationIssues, err := LintMigration(m.ID, m.Up, dialect, rules)
+		if err != nil {
+			return nil, err
+		}
+
+		issues = append(issues, migrationIssues...)
+	}
+
+	return issues, nil
+}
+
+// LintMigration reports the statements of a parsed migration matching the rules for the dialect. If rules is
+// nil, DefaultRules are used. Statements preceded by an Allow directive naming a rule are not reported by it.
+// The bodies of procedural statements, such as functions and triggers, are not checked.
+func LintMigration(id string, parsed *parser.ParsedMigration, dialect migration.Dialect, rules []Rule) ([]Issue, error) {
+	if rules == nil {
+		rules = DefaultRules
+	}
+
+	var issues []Issue
+
+	err := parsed.ForEachStatement(func(chunk string, position parser.Position, options parser.StatementOptions) error {
+		allowed, unknown := allowedRules(id, position, options, rules)
+		issues = append(issues, unknown...)
+
+		for _, statement := range statements(chunk, position, dialect) {
+			for i := range rules {
+				rule := &rules[i]
+
+				if allowed[rule.Name] || !rule.appliesTo(dialect) || !rule.Check(statement.sql) {
+					continue
+				}
+
+				issues = append(issues, Issue{
+					Rule:      rule.Name,
+					Severity:  rule.Severity,
+					Message:   rule.Message,
+					Migration: id,
+					Position:  statement.position,
+					Statement: statement.sql,
+				})
+			}
+		}
+
+		return nil
+	})
+
+	return issues, err
+}
+
+// allowedRules returns the rules allowed by the Allow directive of a statement. Allowed rules that are neither
+// one of the rules nor one of the rules of LintLocks are reported, as the rules of both can be allowed in
+// migrations linted by both.
+func allowedRules(id string, position parser.Position, options parser.StatementOptions, rules []Rule) (map[string]bool, []Issue) {
+	names := map[string]bool{}
+
+	for _, rule := range lockRuleNames {
+		names[rule] = true
+	}
+
+	for _, rule := range rules {
+		names[rule.Name] = true
+	}
+
+	allowed := map[string]bool{}
+
+	var issues []Issue
+
+	for _, rule := range options.Allow {
+		if !names[rule] {
+			issues = append(issues, Issue{
+				Rule:      unknownRule,
+				Severity:  Error,
+				Message:   fmt.Sprintf("the Allow directive names the rule %s, which does not exist", rule),
+				Migration: id,
+				Position:  position,
+			})
+		}
+
+		allowed[rule] = true
+	}
+
+	return allowed, issues
+}
+
+// MaxSeverity returns the highest severity of the issues, or false if there are no issues.
+func MaxSeverity(issues []Issue) (Severity, bool) {
+	if len(issues) == 0 {
+		return Info, false
+	}
+
+	highest := issues[0].Severity
+
+	for _, issue := range issues[1:] {
+		if issue.Severity > highest {
+			highest = issue.Severity
+		}
+	}
+
+	return highest, true
+}
+
+type statement struct {
+	sql      string
+	position parser.Position
+}
+
+// statements splits a chunk of a parsed migration into its statements, without leading comments. The
+// position of the chunk is the position of its first non-whitespace line. Procedural statements, such as
+// functions and triggers, are skipped, as their bodies are not executed by the migration.
+func statements(chunk string, position parser.Position, dialect migration.Dialect) []statement {
+	if sql := parser.StripComments(chunk); sql == "" {
+		return nil
+	}
+
+	lead := len(chunk) - len(strings.TrimLeft(chunk, " \t\r\n"))
+	offset := lead
+
+	// depth is the number of blocks opened by the body of a procedural statement that are not closed yet.
+	depth := 0
+
+	var result []statement
+
+	for _, s := range parser.SplitStatements(chunk, parser.WithDialect(dialect)) {
+		sql := parser.StripComments(s)
+		if sql == "" {
+			continue
+		}
+
+		i := strings.Index(chunk[offset:], sql)
+		if i == -1 {
+			continue
+		}
+
+		p := position
+
+		if p.IsValid() {
+			p.Line += strings.Count(chunk[lead:offset+i], "\n")
+		}
+
+		offset += i + len(sql)
+
+		// Bodies that are not quoted, such as BEGIN ... END blocks of MySQL triggers, are split at their
+		// semicolons, so the statements of the body are skipped until its blocks are closed.
+		if depth > 0 || parser.IsProcedural(sql) {
+			depth += blockDepth(sql)
+			if depth < 0 {
+				depth = 0
+			}
+			continue
+		}
+
+		result = append(result, statement{sql: strings.TrimSuffix(sql, ";"), position: p})
+	}
+
+	return result
+}
+
+// blockDepth returns the number of BEGIN ... END and CASE ... END blocks opened by sql minus the number
+// of blocks it closes. The END of IF, LOOP, WHILE and REPEAT statements does not close a block.
+func blockDepth(sql string) int {
+	depth := len(blockOpenRegex.FindAllString(sql, -1))
+
+	for _, matches := range blockCloseRegex.FindAllStringSubmatch(sql, -1) {
+		switch strings.ToUpper(matches[1]) {
+		case "":
+			depth--
+		case "CASE":
+			// END CASE closes the CASE statement, but its CASE keyword was counted as an opening.
+			depth -= 2
+		}
+	}
+
+	return depth
+}
+
+// anyAlterTableClause returns true if the statement alters a table and one of its comma-separated clauses
+// matches.
+func anyAlterTableClause(statement string, match func(clause string) bool) bool {
+	matches := alterTableRegex.FindStringSubmatch(statement)
+	if matches == nil {
+		return false
+	}
+
+	for _, clause := range splitClauses(matches[1]) {
+		if match(clause) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// splitClauses splits the clauses of an ALTER TABLE statement at commas outside of parentheses and quotes.
+func splitClauses(clauses string) []string {
+	var (
+		result []string
+		depth  int
+		quote  byte
+		start  int
+	)
+
+	for i := 0; i < len(clauses); i++ {
+		c := clauses[i]
+
+		switch {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '(':
+			depth++
+		case c == ')':
+			depth--
+		case c == ',' && depth == 0:
+			result = append(result, strings.TrimSpace(clauses[start:i]))
+			start = i + 1
+		}
+	}
+
+	return append(result, strings.TrimSpace(clauses[start:]))
+}
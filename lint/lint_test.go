@@ -0,0 +1,104 @@
+package lint
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Boostport/migration"
+	"github.com/Boostport/migration/parser"
+)
+
+func TestRules(t *testing.T) {
+	testCases := []struct {
+		statement string
+		dialect   migration.Dialect
+		rules     []string
+	}{
+		{"DROP TABLE test_table1;", migration.Postgres, []string{"drop-table"}},
+		{"ALTER TABLE test_table1 DROP COLUMN name;", migration.Postgres, []string{"drop-column"}},
+		{"ALTER TABLE test_table1 DROP name, ADD COLUMN last_name text;", migration.MySQL, []string{"drop-column"}},
+		{"ALTER TABLE test_table1 DROP CONSTRAINT name_unique;", migration.Postgres, nil},
+		{"ALTER TABLE test_table1 ALTER COLUMN name DROP DEFAULT;", migration.Postgres, nil},
+		{"ALTER TABLE test_table1 ADD COLUMN name text NOT NULL;", migration.Postgres, []string{"add-not-null-column-without-default"}},
+		{"ALTER TABLE test_table1 ADD COLUMN name text NOT NULL DEFAULT '';", migration.Postgres, nil},
+		{"ALTER TABLE test_table1 ADD COLUMN name text, ADD CONSTRAINT name_not_null CHECK (name IS NOT NULL);", migration.Postgres, nil},
+		{"UPDATE test_table1 SET name = 'unknown';", migration.Postgres, []string{"update-without-where"}},
+		{"UPDATE test_table1 SET name = 'unknown' WHERE name IS NULL;", migration.Postgres, nil},
+		{"DELETE FROM test_table1;", migration.SQLite, []string{"delete-without-where"}},
+		{"ALTER TABLE test_table1 RENAME TO test_table2;", migration.SQLite, []string{"rename-table"}},
+		{"RENAME TABLE test_table1 TO test_table2;", migration.MySQL, []string{"rename-table"}},
+		{"ALTER TABLE test_table1 RENAME COLUMN name TO full_name;", migration.Postgres, []string{"rename-column"}},
+		{"ALTER TABLE test_table1 CHANGE COLUMN name full_name text;", migration.MySQL, []string{"change-column"}},
+		{"ALTER TABLE test_table1 CHANGE COLUMN name full_name text;", migration.Postgres, nil},
+		{"CREATE FUNCTION cleanup() RETURNS void AS $$ DELETE FROM test_table1; $$ LANGUAGE sql;", migration.Postgres, nil},
+		{"CREATE FUNCTION cleanup() RETURNS void AS $$ DELETE FROM test_table1; $$ LANGUAGE sql;\nDROP TABLE test_table1;", migration.Postgres, []string{"drop-table"}},
+		{"CREATE TRIGGER test_trigger BEFORE UPDATE ON test_table1 FOR EACH ROW BEGIN\n  IF OLD.id > 0 THEN\n    DELETE FROM test_table2;\n  END IF;\n  UPDATE test_table2 SET name = CASE WHEN OLD.id > 1 THEN 'a' ELSE 'b' END;\nEND;\nUPDATE test_table1 SET name = 'unknown';", migration.MySQL, []string{"update-without-where"}},
+	}
+
+	for _, testCase := range testCases {
+		parsed, err := parser.Parse(strings.NewReader(testCase.statement), parser.WithDialect(testCase.dialect))
+		if err != nil {
+			t.Fatalf("Unexpected error while parsing %q: %s", testCase.statement, err)
+		}
+
+		issues, err := LintMigration("1_init", parsed, testCase.dialect, nil)
+		if err != nil {
+			t.Fatalf("Unexpected error while linting %q: %s", testCase.statement, err)
+		}
+
+		var rules []string
+
+		for _, issue := range issues {
+			rules = append(rules, issue.Rule)
+		}
+
+		if !reflect.DeepEqual(rules, testCase.rules) {
+			t.Errorf("Expected %q to be reported by %v for %s, got %v", testCase.statement, testCase.rules, testCase.dialect, rules)
+		}
+	}
+}
+
+func TestLint(t *testing.T) {
+	source := &migration.MemoryMigrationSource{
+		Files: map[string]string{
+			"1_init.up.sql":      "CREATE TABLE test_table1 (id integer not null primary key, name text);",
+			"1_init.down.sql":    "DROP TABLE test_table1;",
+			"2_cleanup.up.sql":   "-- Remove unused data\nALTER TABLE test_table1 DROP COLUMN name;\n\nDELETE FROM test_table1;\n",
+			"3_allowed.up.sql":   "-- +migration Allow: drop-table\nDROP TABLE legacy_table;\nDROP TABLE test_table1;\n",
+			"4_unknown.up.sql":   "-- +migration Allow: drop-tables\nDROP TABLE test_table2;\n",
+			"5_procedure.up.sql": "-- +migration BeginStatement\nCREATE TRIGGER test_trigger BEFORE UPDATE ON test_table1 FOR EACH ROW BEGIN\n  DELETE FROM test_table2;\nEND\n-- +migration EndStatement\n",
+		},
+	}
+
+	issues, err := Lint(source, migration.Postgres, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error while linting migrations: %s", err)
+	}
+
+	var reported []string
+
+	for _, issue := range issues {
+		reported = append(reported, issue.String())
+	}
+
+	expected := []string{
+		"2_cleanup.up.sql:2: error: drop-column: " + DefaultRules[1].Message,
+		"2_cleanup.up.sql:4: error: delete-without-where: " + DefaultRules[4].Message,
+		"3_allowed.up.sql:3: error: drop-table: " + DefaultRules[0].Message,
+		"4_unknown.up.sql:2: error: unknown-rule: the Allow directive names the rule drop-tables, which does not exist",
+		"4_unknown.up.sql:2: error: drop-table: " + DefaultRules[0].Message,
+	}
+
+	if !reflect.DeepEqual(reported, expected) {
+		t.Errorf("Expected issues:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(reported, "\n"))
+	}
+
+	if severity, ok := MaxSeverity(issues); !ok || severity != Error {
+		t.Errorf("Expected highest severity to be error, got %s", severity)
+	}
+
+	if _, ok := MaxSeverity(nil); ok {
+		t.Error("Expected no severity without issues")
+	}
+}
@@ -0,0 +1,349 @@
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/Boostport/migration"
+	"github.com/Boostport/migration/parser"
+)
+
+// LockMode is a postgres table lock mode. Stronger modes conflict with more operations.
+type LockMode int
+
+// Constants for lock modes, from the weakest to the strongest
+const (
+	NoLock LockMode = iota
+	AccessShare
+	RowShare
+	RowExclusive
+	ShareUpdateExclusive
+	Share
+	ShareRowExclusive
+	Exclusive
+	AccessExclusive
+)
+
+// String returns the name of the lock mode as used by postgres
+func (m LockMode) String() string {
+	switch m {
+	case NoLock:
+		return "NO LOCK"
+	case AccessShare:
+		return "ACCESS SHARE"
+	case RowShare:
+		return "ROW SHARE"
+	case RowExclusive:
+		return "ROW EXCLUSIVE"
+	case ShareUpdateExclusive:
+		return "SHARE UPDATE EXCLUSIVE"
+	case Share:
+		return "SHARE"
+	case ShareRowExclusive:
+		return "SHARE ROW EXCLUSIVE"
+	case Exclusive:
+		return "EXCLUSIVE"
+	case AccessExclusive:
+		return "ACCESS EXCLUSIVE"
+	default:
+		return "UNKNOWN"
+	}
+}
+
+// BlocksWrites returns true if the lock mode conflicts with the ROW EXCLUSIVE lock taken by INSERT, UPDATE
+// and DELETE.
+func (m LockMode) BlocksWrites() bool {
+	return m >= Share
+}
+
+// Lock is the lock acquired by a statement of a postgres migration.
+type Lock struct {
+	Statement string
+	Position  parser.Position
+
+	// Relation is the table or view named by the statement. It is empty if the statement does not lock an
+	// existing table or view, or only names an index of the table it locks.
+	Relation string
+
+	// Index is the index named by statements such as DROP INDEX, which do not name the table of the index.
+	Index string
+
+	Mode LockMode
+
+	// NoTransaction is set for statements that cannot be executed within a transaction, such as
+	// CREATE INDEX CONCURRENTLY.
+	NoTransaction bool
+}
+
+// Names of the rules reported by LintLocks. They can be used in Allow directives.
+const (
+	AccessExclusiveLockRule = "access-exclusive-lock"
+	WriteBlockingLockRule   = "write-blocking-lock"
+	NoTransactionRule       = "requires-no-transaction"
+)
+
+var lockRuleNames = []string{AccessExclusiveLockRule, WriteBlockingLockRule, NoTransactionRule}
+
+// lockRule classifies statements matching its regex. The first submatch of the regex is the relation, or the
+// index if index is set.
+type lockRule struct {
+	regex         *regexp.Regexp
+	mode          LockMode
+	noTransaction bool
+	index         bool
+}
+
+const ifExists = `(?:IF\s+(?:NOT\s+)?EXISTS\s+)?`
+
+var lockRules = []lockRule{
+	{regex: regexp.MustCompile(`(?is)^CREATE\s+(?:UNIQUE\s+)?INDEX\s+CONCURRENTLY\s+` + ifExists + `(?:` + name + `\s+)?ON\s+(?:ONLY\s+)?(` + name + `)`), mode: ShareUpdateExclusive, noTransaction: true},
+	{regex: regexp.MustCompile(`(?is)^CREATE\s+(?:UNIQUE\s+)?INDEX\s+` + ifExists + `(?:` + name + `\s+)?ON\s+(?:ONLY\s+)?(` + name + `)`), mode: Share},
+	{regex: regexp.MustCompile(`(?is)^DROP\s+INDEX\s+CONCURRENTLY\s+` + ifExists + `(` + name + `)`), mode: ShareUpdateExclusive, noTransaction: true, index: true},
+	{regex: regexp.MustCompile(`(?is)^DROP\s+INDEX\s+` + ifExists + `(` + name + `)`), mode: AccessExclusive, index: true},
+	{regex: regexp.MustCompile(`(?is)^REINDEX\s+(?:\([^)]*\)\s+)?INDEX\s+CONCURRENTLY\s+(` + name + `)`), mode: ShareUpdateExclusive, noTransaction: true, index: true},
+	{regex: regexp.MustCompile(`(?is)^REINDEX\s+(?:\([^)]*\)\s+)?INDEX\s+(` + name + `)`), mode: AccessExclusive, index: true},
+	{regex: regexp.MustCompile(`(?is)^REINDEX\s+(?:\([^)]*\)\s+)?TABLE\s+CONCURRENTLY\s+(` + name + `)`), mode: ShareUpdateExclusive, noTransaction: true},
+	{regex: regexp.MustCompile(`(?is)^REINDEX\s+(?:\([^)]*\)\s+)?TABLE\s+(` + name + `)`), mode: AccessExclusive},
+	{regex: regexp.MustCompile(`(?is)^ALTER\s+INDEX\s+` + ifExists + `(` + name + `)\s+RENAME\s`), mode: ShareUpdateExclusive, index: true},
+	{regex: regexp.MustCompile(`(?is)^ALTER\s+INDEX\s+` + ifExists + `(` + name + `)`), mode: AccessExclusive, index: true},
+	{regex: regexp.MustCompile(`(?is)^(?:DROP\s+TABLE\s+` + ifExists + `|TRUNCATE\s+(?:TABLE\s+)?(?:ONLY\s+)?|CLUSTER\s+(?:VERBOSE\s+)?)(` + name + `)`), mode: AccessExclusive},
+	{regex: regexp.MustCompile(`(?is)^VACUUM\s+(?:\([^)]*\bFULL\b[^)]*\)|FULL)\s+(?:\w+\s+)*?(` + name + `)\s*;?$`), mode: AccessExclusive, noTransaction: true},
+	{regex: regexp.MustCompile(`(?is)^VACUUM\b(?:\s+\([^)]*\))?(?:\s+\w+)*?(?:\s+(` + name + `))?\s*;?$`), mode: ShareUpdateExclusive, noTransaction: true},
+	{regex: regexp.MustCompile(`(?is)^REFRESH\s+MATERIALIZED\s+VIEW\s+CONCURRENTLY\s+(` + name + `)`), mode: Exclusive},
+	{regex: regexp.MustCompile(`(?is)^REFRESH\s+MATERIALIZED\s+VIEW\s+(` + name + `)`), mode: AccessExclusive},
+	{regex: regexp.MustCompile(`(?is)^CREATE\s+(?:OR\s+REPLACE\s+)?(?:CONSTRAINT\s+)?TRIGGER\s+` + name + `\s+.*?\bON\s+(` + name + `)`), mode: ShareRowExclusive},
+	{regex: regexp.MustCompile(`(?is)^DROP\s+TRIGGER\s+` + ifExists + name + `\s+ON\s+(` + name + `)`), mode: AccessExclusive},
+	{regex: regexp.MustCompile(`(?is)^(?:CREATE|DROP)\s+DATABASE\s+` + ifExists + `()`), noTransaction: true},
+	{regex: regexp.MustCompile(`(?is)^(?:INSERT\s+INTO|UPDATE(?:\s+ONLY)?|DELETE\s+FROM(?:\s+ONLY)?|MERGE\s+INTO)\s+(` + name + `)`), mode: RowExclusive},
+}
+
+var (
+	lockTableRegex = regexp.MustCompile(`(?is)^LOCK\s+(?:TABLE\s+)?(?:ONLY\s+)?(` + name + `)(?:\s+IN\s+(.+?)\s+MODE)?`)
+
+	alterTableLockRegex = regexp.MustCompile(`(?is)^ALTER\s+TABLE\s+` + ifExists + `(?:ONLY\s+)?(` + name + `)\s+(.*)$`)
+
+	shareUpdateExclusiveClauseRegex = regexp.MustCompile(`(?is)^(?:VALIDATE\s+CONSTRAINT|ALTER\s+(?:COLUMN\s+)?` + identifier + `\s+SET\s+STATISTICS|SET\s*\(|RESET\s*\(|CLUSTER\s+ON|SET\s+WITHOUT\s+CLUSTER|ATTACH\s+PARTITION|DETACH\s+PARTITION\s+` + name + `\s+CONCURRENTLY)`)
+	shareRowExclusiveClauseRegex    = regexp.MustCompile(`(?is)^(?:ADD\s+(?:CONSTRAINT\s+` + identifier + `\s+)?FOREIGN\s+KEY|(?:ENABLE|DISABLE)\s+(?:ALWAYS\s+|REPLICA\s+)?TRIGGER)`)
+	detachConcurrentlyRegex         = regexp.MustCompile(`(?is)^DETACH\s+PARTITION\s+` + name + `\s+CONCURRENTLY`)
+
+	lockModes = map[string]LockMode{
+		"ACCESS SHARE":           AccessShare,
+		"ROW SHARE":              RowShare,
+		"ROW EXCLUSIVE":          RowExclusive,
+		"SHARE UPDATE EXCLUSIVE": ShareUpdateExclusive,
+		"SHARE":                  Share,
+		"SHARE ROW EXCLUSIVE":    ShareRowExclusive,
+		"EXCLUSIVE":              Exclusive,
+		"ACCESS EXCLUSIVE":       AccessExclusive,
+	}
+)
+
+// PostgresLocks classifies the statements of a parsed postgres migration by the lock they acquire on the
+// relation they name. The bodies of procedural statements, such as functions and triggers, are not
+// classified, but the statements following them are.
+func PostgresLocks(parsed *parser.ParsedMigration) ([]Lock, error) {
+	var locks []Lock
+
+	err := parsed.ForEachStatement(func(chunk string, position parser.Position, _ parser.StatementOptions) error {
+		for _, statement := range statements(chunk, position, migration.Postgres) {
+			locks = append(locks, classifyLock(statement))
+		}
+
+		return nil
+	})
+
+	return locks, err
+}
+
+func classifyLock(statement statement) Lock {
+	lock := Lock{
+		Statement: statement.sql,
+		Position:  statement.position,
+	}
+
+	if matches := alterTableLockRegex.FindStringSubmatch(statement.sql); matches != nil {
+		lock.Relation = matches[1]
+		lock.Mode = NoLock
+
+		// The lock of the statement is the strongest lock of its clauses
+		for _, clause := range splitClauses(matches[2]) {
+			mode := AccessExclusive
+
+			switch {
+			case shareUpdateExclusiveClauseRegex.MatchString(clause):
+				mode = ShareUpdateExclusive
+			case shareRowExclusiveClauseRegex.MatchString(clause):
+				mode = ShareRowExclusive
+			}
+
+			if mode > lock.Mode {
+				lock.Mode = mode
+			}
+
+			if detachConcurrentlyRegex.MatchString(clause) {
+				lock.NoTransaction = true
+			}
+		}
+
+		return lock
+	}
+
+	if matches := lockTableRegex.FindStringSubmatch(statement.sql); matches != nil {
+		lock.Relation = matches[1]
+		lock.Mode = AccessExclusive
+
+		if mode, ok := lockModes[strings.ToUpper(strings.Join(strings.Fields(matches[2]), " "))]; ok {
+			lock.Mode = mode
+		}
+
+		return lock
+	}
+
+	for _, rule := range lockRules {
+		if matches := rule.regex.FindStringSubmatch(statement.sql); matches != nil {
+			if rule.index {
+				lock.Index = matches[1]
+			} else {
+				lock.Relation = matches[1]
+			}
+
+			lock.Mode = rule.mode
+			lock.NoTransaction = rule.noTransaction
+
+			return lock
+		}
+	}
+
+	return lock
+}
+
+// LintLocks reports the statements of the up migrations in the source that cannot be executed within the
+// transaction of their migration, and the statements taking locks blocking production traffic on big tables.
+// The rules are the rules the migrations are linted with by Lint, which Allow directives may name as well. If
+// rules is nil, DefaultRules are used. The options are used for parsing the migrations, as they would be by
+// Migrate.
+func LintLocks(source migration.Source, bigTables []string, rules []Rule, opts ...migration.Option) ([]Issue, error) {
+	migrations, err := migration.Migrations(source, migration.Postgres, opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []Issue
+
+	for _, m := range migrations {
+		if m.Up == nil {
+			continue
+		}
+
+		migrationIssues, err := LintMigrationLocks(m.ID, m.Up, bigTables, rules)
+		if err != nil {
+			return nil, err
+		}
+
+		issues = append(issues, migrationIssues...)
+	}
+
+	return issues, nil
+}
+
+// LintMigrationLocks reports the statements of a parsed postgres migration that cannot be executed within
+// the transaction of the migration, because it is not marked with NoTransaction, as errors. ACCESS EXCLUSIVE
+// locks, which block reads, and other locks blocking writes on the big tables are reported as warnings.
+// Statements naming only an index, such as DROP INDEX, are not checked against the big tables, as the table
+// of the index is not known.
+// Statements preceded by an Allow directive naming a rule are not reported by it, and Allow directives naming
+// neither a lock rule nor one of the rules are reported. If rules is nil, DefaultRules are used.
+func LintMigrationLocks(id string, parsed *parser.ParsedMigration, bigTables []string, rules []Rule) ([]Issue, error) {
+	if rules == nil {
+		rules = DefaultRules
+	}
+
+	var issues []Issue
+
+	err := parsed.ForEachStatement(func(chunk string, position parser.Position, options parser.StatementOptions) error {
+		allowed, unknown := allowedRules(id, position, options, rules)
+		issues = append(issues, unknown...)
+
+		for _, statement := range statements(chunk, position, migration.Postgres) {
+			lock := classifyLock(statement)
+
+			issue := Issue{
+				Migration: id,
+				Position:  lock.Position,
+				Statement: lock.Statement,
+			}
+
+			if lock.NoTransaction && parsed.UseTransaction && !allowed[NoTransactionRule] {
+				issue.Rule = NoTransactionRule
+				issue.Severity = Error
+				issue.Message = "the statement cannot be executed within a transaction, so the migration must be marked with NoTransaction"
+				issues = append(issues, issue)
+			}
+
+			if !isBigTable(lock.Relation, bigTables) {
+				continue
+			}
+
+			switch {
+			case lock.Mode == AccessExclusive && !allowed[AccessExclusiveLockRule]:
+				issue.Rule = AccessExclusiveLockRule
+			case lock.Mode.BlocksWrites() && lock.Mode != AccessExclusive && !allowed[WriteBlockingLockRule]:
+				issue.Rule = WriteBlockingLockRule
+			default:
+				continue
+			}
+
+			issue.Severity = Warning
+			issue.Message = fmt.Sprintf("the statement locks the big table %s in %s mode, which blocks %s until the migration ends", lock.Relation, lock.Mode, blockedOperations(lock.Mode))
+			issues = append(issues, issue)
+		}
+
+		return nil
+	})
+
+	return issues, err
+}
+
+func blockedOperations(mode LockMode) string {
+	if mode == AccessExclusive {
+		return "reads and writes"
+	}
+
+	return "writes"
+}
+
+// isBigTable returns true if the relation is one of the big tables. Big tables without a schema match
+// relations in any schema.
+func isBigTable(relation string, bigTables []string) bool {
+	if relation == "" {
+		return false
+	}
+
+	relation = normalizeName(relation)
+
+	for _, table := range bigTables {
+		table = normalizeName(table)
+
+		if relation == table || (!strings.Contains(table, ".") && strings.HasSuffix(relation, "."+table)) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// normalizeName removes the quotes of a name. Unquoted names are folded to lower case, like postgres does.
+func normalizeName(name string) string {
+	parts := strings.Split(name, ".")
+
+	for i, part := range parts {
+		if strings.HasPrefix(part, `"`) && strings.HasSuffix(part, `"`) && len(part) > 1 {
+			parts[i] = part[1 : len(part)-1]
+		} else {
+			parts[i] = strings.ToLower(part)
+		}
+	}
+
+	return strings.Join(parts, ".")
+}
@@ -0,0 +1,123 @@
+package lint
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Boostport/migration"
+	"github.com/Boostport/migration/parser"
+)
+
+func TestPostgresLocks(t *testing.T) {
+	testCases := []struct {
+		statement     string
+		relation      string
+		index         string
+		mode          LockMode
+		noTransaction bool
+	}{
+		{"CREATE INDEX test_index ON test_table1 (name);", "test_table1", "", Share, false},
+		{"CREATE UNIQUE INDEX IF NOT EXISTS test_index ON public.test_table1 USING btree (name);", "public.test_table1", "", Share, false},
+		{"CREATE INDEX CONCURRENTLY test_index ON test_table1 (name);", "test_table1", "", ShareUpdateExclusive, true},
+		{"DROP INDEX CONCURRENTLY IF EXISTS test_index;", "", "test_index", ShareUpdateExclusive, true},
+		{"DROP INDEX test_index;", "", "test_index", AccessExclusive, false},
+		{"REINDEX INDEX public.test_index;", "", "public.test_index", AccessExclusive, false},
+		{"ALTER INDEX test_index RENAME TO test_index2;", "", "test_index", ShareUpdateExclusive, false},
+		{"REINDEX TABLE CONCURRENTLY test_table1;", "test_table1", "", ShareUpdateExclusive, true},
+		{"ALTER TABLE test_table1 ALTER COLUMN name SET NOT NULL;", "test_table1", "", AccessExclusive, false},
+		{"ALTER TABLE test_table1 VALIDATE CONSTRAINT name_not_null;", "test_table1", "", ShareUpdateExclusive, false},
+		{"ALTER TABLE test_table1 ADD CONSTRAINT test_fk FOREIGN KEY (parent_id) REFERENCES test_table2 (id) NOT VALID;", "test_table1", "", ShareRowExclusive, false},
+		{"ALTER TABLE test_table1 VALIDATE CONSTRAINT test_fk, ADD COLUMN age integer;", "test_table1", "", AccessExclusive, false},
+		{"ALTER TABLE test_table1 DETACH PARTITION test_table1_2020 CONCURRENTLY;", "test_table1", "", ShareUpdateExclusive, true},
+		{"LOCK TABLE test_table1 IN SHARE ROW EXCLUSIVE MODE;", "test_table1", "", ShareRowExclusive, false},
+		{"LOCK test_table1;", "test_table1", "", AccessExclusive, false},
+		{"TRUNCATE test_table1;", "test_table1", "", AccessExclusive, false},
+		{"VACUUM ANALYZE test_table1;", "test_table1", "", ShareUpdateExclusive, true},
+		{"VACUUM FULL test_table1;", "test_table1", "", AccessExclusive, true},
+		{"REFRESH MATERIALIZED VIEW CONCURRENTLY test_view;", "test_view", "", Exclusive, false},
+		{"UPDATE test_table1 SET name = 'unknown' WHERE name IS NULL;", "test_table1", "", RowExclusive, false},
+		{"CREATE TABLE test_table3 (id integer not null primary key);", "", "", NoLock, false},
+		{"CREATE FUNCTION f() RETURNS integer AS $$ SELECT 1; $$ LANGUAGE sql;\nCREATE INDEX CONCURRENTLY idx ON users (name);", "users", "", ShareUpdateExclusive, true},
+	}
+
+	for _, testCase := range testCases {
+		parsed, err := parser.Parse(strings.NewReader(testCase.statement), parser.WithDialect(migration.Postgres))
+		if err != nil {
+			t.Fatalf("Unexpected error while parsing %q: %s", testCase.statement, err)
+		}
+
+		locks, err := PostgresLocks(parsed)
+		if err != nil {
+			t.Fatalf("Unexpected error while classifying %q: %s", testCase.statement, err)
+		}
+
+		if len(locks) != 1 {
+			t.Fatalf("Expected 1 lock for %q, got %d", testCase.statement, len(locks))
+		}
+
+		lock := locks[0]
+
+		if lock.Relation != testCase.relation || lock.Mode != testCase.mode || lock.NoTransaction != testCase.noTransaction {
+			t.Errorf("Expected %q to lock %q in %s mode (no transaction: %t), got %q in %s mode (no transaction: %t)", testCase.statement, testCase.relation, testCase.mode, testCase.noTransaction, lock.Relation, lock.Mode, lock.NoTransaction)
+		}
+
+		if lock.Index != testCase.index {
+			t.Errorf("Expected %q to name the index %q, got %q", testCase.statement, testCase.index, lock.Index)
+		}
+	}
+}
+
+func TestLintLocks(t *testing.T) {
+	source := &migration.MemoryMigrationSource{
+		Files: map[string]string{
+			"1_index.up.sql":         "CREATE INDEX CONCURRENTLY test_index ON test_table1 (name);",
+			"2_index.up.sql":         "-- +migration NoTransaction\nCREATE INDEX CONCURRENTLY test_index ON test_table1 (name);\nCREATE INDEX test_index2 ON test_table2 (name);\n",
+			"3_not_null.up.sql":      "ALTER TABLE \"Users\" ALTER COLUMN name SET NOT NULL;\nALTER TABLE test_table2 ALTER COLUMN name SET NOT NULL;\n",
+			"4_allowed.up.sql":       "-- +migration Allow: access-exclusive-lock\nALTER TABLE public.test_table1 ADD COLUMN age integer;\n",
+			"5_small_table.up.sql":   "ALTER TABLE test_table1 RENAME COLUMN name TO full_name;\n",
+			"5_small_table.down.sql": "ALTER TABLE test_table1 RENAME COLUMN full_name TO name;\n",
+			"6_function.up.sql":      "CREATE FUNCTION f() RETURNS integer AS $$ SELECT 1; $$ LANGUAGE sql;\nCREATE INDEX CONCURRENTLY idx ON users (name);\n",
+			"7_unknown.up.sql":       "-- +migration Allow: drop-table, write-blocking-locks\nCREATE INDEX test_index3 ON test_table2 (name);\n",
+		},
+	}
+
+	issues, err := LintLocks(source, []string{"test_table2", "\"Users\"", "public.test_table1", "users"}, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error while linting migrations: %s", err)
+	}
+
+	var reported []string
+
+	for _, issue := range issues {
+		reported = append(reported, issue.String())
+	}
+
+	expected := []string{
+		"1_index.up.sql:1: error: requires-no-transaction: the statement cannot be executed within a transaction, so the migration must be marked with NoTransaction",
+		"2_index.up.sql:3: warning: write-blocking-lock: the statement locks the big table test_table2 in SHARE mode, which blocks writes until the migration ends",
+		"3_not_null.up.sql:1: warning: access-exclusive-lock: the statement locks the big table \"Users\" in ACCESS EXCLUSIVE mode, which blocks reads and writes until the migration ends",
+		"3_not_null.up.sql:2: warning: access-exclusive-lock: the statement locks the big table test_table2 in ACCESS EXCLUSIVE mode, which blocks reads and writes until the migration ends",
+		"6_function.up.sql:2: error: requires-no-transaction: the statement cannot be executed within a transaction, so the migration must be marked with NoTransaction",
+		"7_unknown.up.sql:2: error: unknown-rule: the Allow directive names the rule write-blocking-locks, which does not exist",
+		"7_unknown.up.sql:2: warning: write-blocking-lock: the statement locks the big table test_table2 in SHARE mode, which blocks writes until the migration ends",
+	}
+
+	if !reflect.DeepEqual(reported, expected) {
+		t.Errorf("Expected issues:\n%s\ngot:\n%s", strings.Join(expected, "\n"), strings.Join(reported, "\n"))
+	}
+
+	// Lock rules can be allowed in migrations checked by Lint as well
+	delete(source.Files, "7_unknown.up.sql")
+
+	issues, err = Lint(source, migration.Postgres, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error while linting migrations: %s", err)
+	}
+
+	for _, issue := range issues {
+		if issue.Rule == unknownRule {
+			t.Errorf("Unexpected issue: %s", issue)
+		}
+	}
+}
@@ -1,12 +1,14 @@
 package migration
 
 import (
-	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"path"
 	"regexp"
 	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/Boostport/migration/parser"
 )
@@ -32,13 +34,41 @@ const (
 	Down
 )
 
-var numberPrefixRegex = regexp.MustCompile(`^(\d+).*$`)
+var (
+	numberPrefixRegex   = regexp.MustCompile(`^(\d+).*$`)
+	migrationFilesRegex = regexp.MustCompile(`(\d*_.*)\.(up|down)\..*`)
+
+	// Single files contain both the up and down migration, such as 7_add_index.sql or 7_add_index.postgres.sql.
+	singleMigrationFileRegex = regexp.MustCompile(`^(\d*_[^./]*)(?:\.([^./]+))?\.sql(?:\.tmpl)?$`)
+
+	// variantDialects are the dialects migration files can be specific to, such as 4_uuid.up.postgres.sql.
+	variantDialects = []Dialect{Postgres, MySQL, SQLite, Phoenix}
+)
+
+func isVariantDialect(dialect Dialect) bool {
+	for _, d := range variantDialects {
+		if d == dialect {
+			return true
+		}
+	}
+
+	return false
+}
 
 // Migration represents a migration, containing statements for migrating up and down.
 type Migration struct {
 	ID   string
 	Up   *parser.ParsedMigration
 	Down *parser.ParsedMigration
+
+	// UpFunc and DownFunc are set for Go migrations. SQL drivers execute them within
+	// the transaction of the migration.
+	UpFunc   TxMigrationFunc
+	DownFunc TxMigrationFunc
+
+	// baseline is set for migrations that have not been loaded, if the header of their up file
+	// declares a baseline.
+	baseline bool
 }
 
 // PlannedMigration is a migration with a direction defined. This allows the driver to
@@ -62,6 +92,19 @@ func (m Migration) Less(other *Migration) bool {
 	}
 }
 
+// parsed returns the parsed migration of the direction.
+func (m Migration) parsed(direction Direction) *parser.ParsedMigration {
+	if direction == Down {
+		return m.Down
+	}
+
+	return m.Up
+}
+
+func (m Migration) isBaseline() bool {
+	return m.baseline || (m.Up != nil && m.Up.Baseline)
+}
+
 func (m Migration) isNumeric() bool {
 	return len(m.NumberPrefixMatches()) > 0
 }
@@ -90,10 +133,28 @@ func (b byID) Less(i, j int) bool { return b[i].Less(b[j]) }
 // Migrate runs a migration using a given driver and MigrationSource. The direction defines whether
 // the migration is up or down, and max is the maximum number of migrations to apply. If max is set to 0,
 // then there is no limit on the number of migrations to apply.
-func Migrate(driver Driver, migrations Source, direction Direction, max int) (int, error) {
-	count := 0
+func Migrate(driver Driver, migrations Source, direction Direction, max int, opts ...Option) (int, error) {
+	return MigrateContext(context.Background(), driver, migrations, direction, max, opts...)
+}
 
-	m, err := getMigrations(migrations)
+// MigrateContext is like Migrate, but the context is passed down to drivers implementing ContextDriver.
+func MigrateContext(ctx context.Context, driver Driver, migrations Source, direction Direction, max int, opts ...Option) (count int, err error) {
+	cfg := newConfig(opts)
+
+	if cfg.tracer != nil {
+		ctx = contextWithTracer(ctx, cfg.tracer)
+	}
+
+	ctx, end := startRun(ctx, direction)
+	defer func() {
+		end(count, err)
+	}()
+
+	if d, ok := driver.(DialectDriver); ok {
+		cfg.dialect = d.Dialect()
+	}
+
+	loader, err := listMigrations(migrations, cfg)
 	if err != nil {
 		return count, err
 	}
@@ -103,20 +164,35 @@ func Migrate(driver Driver, migrations Source, direction Direction, max int) (in
 		return count, err
 	}
 
-	migrationsToApply := planMigrations(m, appliedMigrations, direction, max)
-	for _, plannedMigration := range migrationsToApply {
+	migrationsToApply, err := loader.plan(appliedMigrations, direction, max)
+	if err != nil {
+		return count, err
+	}
+
+	if d, ok := driver.(TransactionalDriver); ok && cfg.atomic && usesTransactions(driver) {
+		count, err = migrateAtomically(ctx, d, migrationsToApply)
+		if err != nil {
+			return count, err
+		}
+
+		err = driver.Close()
+		return count, err
+	}
+
+	migrate := driverMigrateFunc(driver)
+
+	for i, plannedMigration := range migrationsToApply {
 		logPrintf("Applying migration (%s) named '%s'...", direction.String(), plannedMigration.ID)
 
-		err = driver.Migrate(plannedMigration)
+		err = applyMigration(ctx, migrate, plannedMigration)
 		if err != nil {
-			errorMessage := "Error while running migration " + plannedMigration.ID
+			err = migrationError(plannedMigration, err)
 
-			if plannedMigration.Direction == Up {
-				errorMessage += " (up)"
-			} else {
-				errorMessage += " (down)"
+			if cfg.atomic {
+				return compensate(ctx, migrate, migrationsToApply[:i], err)
 			}
-			return count, fmt.Errorf(errorMessage+": %s", err)
+
+			return count, err
 		}
 
 		logPrintf("Applied migration (%s) named '%s'", direction.String(), plannedMigration.ID)
@@ -127,63 +203,589 @@ func Migrate(driver Driver, migrations Source, direction Direction, max int) (in
 	return count, err
 }
 
-func getMigrations(migrations Source) ([]*Migration, error) {
-	var m []*Migration
+// usesTransactions returns false if the driver reports that it does not use transactions.
+func usesTransactions(driver Driver) bool {
+	if d, ok := driver.(TransactionReporter); ok {
+		return d.UsesTransactions()
+	}
+
+	return true
+}
+
+// migrateAtomically applies the migrations within a single transaction of the driver.
+func migrateAtomically(ctx context.Context, driver TransactionalDriver, migrations []*PlannedMigration) (int, error) {
+	tx, err := driver.BeginTx(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("Error starting transaction: %s", err)
+	}
+
+	for _, plannedMigration := range migrations {
+		logPrintf("Applying migration (%s) named '%s'...", plannedMigration.Direction.String(), plannedMigration.ID)
+
+		err = applyMigration(ctx, tx.MigrateContext, plannedMigration)
+		if err != nil {
+			err = migrationError(plannedMigration, err)
+
+			if errRb := tx.Rollback(); errRb != nil {
+				return 0, fmt.Errorf("%s; error rolling back the run: %s", err, errRb)
+			}
+
+			return 0, fmt.Errorf("%s; all migrations of the run were rolled back", err)
+		}
+
+		logPrintf("Applied migration (%s) named '%s'", plannedMigration.Direction.String(), plannedMigration.ID)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return 0, fmt.Errorf("Error committing the run: %s", err)
+	}
+
+	return len(migrations), nil
+}
+
+// compensate reverts the applied migrations in reverse order after a migration of the run failed with
+// err. It returns the number of migrations that are still applied.
+func compensate(ctx context.Context, migrate migrateFunc, applied []*PlannedMigration, err error) (int, error) {
+	for i := len(applied) - 1; i >= 0; i-- {
+		reverse := &PlannedMigration{
+			Migration: applied[i].Migration,
+			Direction: Up,
+		}
+
+		if applied[i].Direction == Up {
+			reverse.Direction = Down
+		}
+
+		logPrintf("Reverting migration (%s) named '%s'...", reverse.Direction.String(), reverse.ID)
+
+		errCompensate := revert(ctx, migrate, reverse)
+		if errCompensate != nil {
+			return i + 1, fmt.Errorf("%s; error reverting the run: %s: %d migrations of the run are still applied", err, migrationError(reverse, errCompensate), i+1)
+		}
+
+		logPrintf("Reverted migration (%s) named '%s'", reverse.Direction.String(), reverse.ID)
+	}
+
+	return 0, fmt.Errorf("%s; all migrations of the run were reverted", err)
+}
+
+func revert(ctx context.Context, migrate migrateFunc, migration *PlannedMigration) error {
+	statements, migrationFunc := migration.Up, migration.UpFunc
+
+	if migration.Direction == Down {
+		statements, migrationFunc = migration.Down, migration.DownFunc
+	}
+
+	if statements == nil && migrationFunc == nil {
+		return fmt.Errorf("migration does not have a %s migration", migration.Direction)
+	}
+
+	return applyMigration(ctx, migrate, migration)
+}
+
+type migrateFunc func(ctx context.Context, migration *PlannedMigration) error
+
+func driverMigrateFunc(driver Driver) migrateFunc {
+	if d, ok := driver.(ContextDriver); ok {
+		return d.MigrateContext
+	}
+
+	return func(_ context.Context, migration *PlannedMigration) error {
+		return driver.Migrate(migration)
+	}
+}
+
+func applyMigration(ctx context.Context, migrate migrateFunc, migration *PlannedMigration) (err error) {
+	ctx, end := startMigration(ctx, migration)
+	defer func() {
+		end(err)
+	}()
+
+	return migrate(ctx, migration)
+}
+
+func migrationError(migration *PlannedMigration, err error) error {
+	errorMessage := "Error while running migration " + migration.ID
+
+	if migration.Direction == Up {
+		errorMessage += " (up)"
+	} else {
+		errorMessage += " (down)"
+	}
+
+	return fmt.Errorf(errorMessage+": %s", err)
+}
+
+// migrationLoader reads and parses the files of the listed migrations when they are needed.
+type migrationLoader struct {
+	source Source
+	cfg    *config
+
+	migrations []*Migration
+
+	// The files defining each migration
+	files map[string][]string
+
+	loaded map[string]bool
+}
+
+// listMigrations lists the migrations of the source without reading their files. Of the files defining a
+// direction of a migration, the variant for the dialect of the driver is picked, or else the generic file.
+func listMigrations(migrations Source, cfg *config) (*migrationLoader, error) {
+	loader := &migrationLoader{
+		source: migrations,
+		cfg:    cfg,
+		files:  map[string][]string{},
+		loaded: map[string]bool{},
+	}
+
 	tempMigrations := map[string]*Migration{}
 
+	type direction struct {
+		id, name string
+	}
+
+	var directions []direction
+
+	// The files defining each direction of the migrations by dialect. Generic files have an empty dialect.
+	definedBy := map[direction]map[Dialect]string{}
+
 	files, err := migrations.ListMigrationFiles()
 	if err != nil {
-		return m, err
+		return nil, err
 	}
 
-	regex := regexp.MustCompile(`(\d*_.*)\.(up|down)\..*`)
-
 	for _, file := range files {
-		matches := regex.FindStringSubmatch(file)
+		id, names, dialect, ok := parseMigrationFileName(file)
+		if !ok {
+			continue
+		}
 
-		if len(matches) > 0 && file == matches[0] {
-			id := matches[1]
-			direction := matches[2]
+		if dialect != "" && !isVariantDialect(dialect) {
+			return nil, fmt.Errorf("Error getting migrations: %s is specific to the unknown dialect %s, known dialects are %s", file, dialect, joinDialects(variantDialects))
+		}
 
-			if _, ok := tempMigrations[id]; !ok {
-				tempMigrations[id] = &Migration{
-					ID: id,
-				}
+		if _, ok := tempMigrations[id]; !ok {
+			tempMigrations[id] = &Migration{
+				ID: id,
 			}
+		}
 
-			reader, err := migrations.GetMigrationFile(file)
-			if err != nil {
-				return m, fmt.Errorf("Error getting migrations: %s", err)
+		for _, name := range names {
+			d := direction{id: id, name: name}
+
+			if _, ok := definedBy[d]; !ok {
+				definedBy[d] = map[Dialect]string{}
+				directions = append(directions, d)
 			}
 
-			contents, err := io.ReadAll(reader)
-			if err != nil {
-				return m, fmt.Errorf("Error getting migration content: %s", err)
+			if _, ok := definedBy[d][dialect]; ok {
+				return nil, fmt.Errorf("Error getting migrations: migration %s (%s) is defined by more than one file", id, name)
 			}
 
-			parsed, err := parser.Parse(bytes.NewReader(contents))
-			if err != nil {
-				return m, fmt.Errorf("Error parsing migration %s: %s", id, err)
+			definedBy[d][dialect] = file
+		}
+	}
+
+	// Pick the variant of each direction for the dialect of the driver, or the generic file
+	for _, d := range directions {
+		file, ok := definedBy[d][cfg.dialect]
+		if !ok {
+			file, ok = definedBy[d][""]
+		}
+
+		if !ok {
+			if cfg.dialect == "" {
+				return nil, fmt.Errorf("Error getting migrations: migration %s (%s) only has dialect-specific files, but the dialect of the driver is unknown", d.id, d.name)
 			}
 
-			if direction == "up" {
-				tempMigrations[id].Up = parsed
-			} else {
-				tempMigrations[id].Down = parsed
+			return nil, fmt.Errorf("Error getting migrations: migration %s (%s) does not have a file for the %s dialect", d.id, d.name, cfg.dialect)
+		}
+
+		// Single files define both directions
+		if !containsString(loader.files[d.id], file) {
+			loader.files[d.id] = append(loader.files[d.id], file)
+		}
+	}
+
+	for id, migration := range tempMigrations {
+		sort.Strings(loader.files[id])
+		loader.migrations = append(loader.migrations, migration)
+	}
+
+	sort.Sort(byID(loader.migrations))
+
+	return loader, nil
+}
+
+// parseMigrationFileName returns the ID of the migration defined by a file, the directions it defines and the
+// dialect it is specific to. The dialect is empty for generic files, and may not be one of the variantDialects.
+// Files listed in subdirectories are identified by their name.
+func parseMigrationFileName(file string) (string, []string, Dialect, bool) {
+	file = path.Base(file)
+
+	if matches := migrationFilesRegex.FindStringSubmatch(file); len(matches) > 0 && file == matches[0] {
+		var dialect Dialect
+
+		// The dialect is followed by the SQL extension, such as 4_uuid.up.postgres.sql
+		if parts := strings.SplitN(strings.TrimPrefix(file, matches[1]+"."+matches[2]+"."), ".", 2); len(parts) == 2 && (parts[1] == "sql" || parts[1] == "sql"+templateExtension) {
+			dialect = Dialect(parts[0])
+		}
+
+		return matches[1], []string{matches[2]}, dialect, true
+	}
+
+	if matches := singleMigrationFileRegex.FindStringSubmatch(file); len(matches) > 0 {
+		return matches[1], []string{"up", "down"}, Dialect(matches[2]), true
+	}
+
+	return "", nil, "", false
+}
+
+func joinDialects(dialects []Dialect) string {
+	names := make([]string, len(dialects))
+
+	for i, dialect := range dialects {
+		names[i] = string(dialect)
+	}
+
+	return strings.Join(names, ", ")
+}
+
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// plan plans the migrations of a run, only loading the migrations needed for planning and running them.
+func (l *migrationLoader) plan(appliedMigrations []string, direction Direction, max int) ([]*PlannedMigration, error) {
+	// Baselines are declared in the headers of the migrations. If the database is not empty, the migrations
+	// that were not applied yet are checked to find out whether they are baselines. The migrations before
+	// the newest applied one are caught up unless they are baselines, and up runs apply the migrations after
+	// it, so these are loaded. Only the headers of the migrations beyond the limit of an up run are read.
+	if len(appliedMigrations) > 0 {
+		applied := map[string]bool{}
+
+		for _, id := range appliedMigrations {
+			applied[id] = true
+		}
+
+		newest := -1
+
+		for i, migration := range l.migrations {
+			if applied[migration.ID] {
+				newest = i
 			}
 		}
+
+		count := 0
+
+		for i, migration := range l.migrations {
+			var err error
+
+			switch {
+			case applied[migration.ID]:
+			case i < newest:
+				err = l.load(migration)
+			case direction == Up && (max <= 0 || count < max):
+				count++
+				err = l.load(migration)
+			case direction == Up:
+				err = l.readBaseline(migration)
+			}
+
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	planned, err := planMigrations(l.migrations, appliedMigrations, direction, max)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, plannedMigration := range planned {
+		if err := l.load(plannedMigration.Migration); err != nil {
+			return nil, err
+		}
+	}
+
+	return planned, nil
+}
+
+// load reads and parses the files of a migration, unless it was already loaded.
+func (l *migrationLoader) load(migration *Migration) error {
+	if l.loaded[migration.ID] {
+		return nil
+	}
+
+	files := l.files[migration.ID]
+	key, cacheable := l.cfg.cache.key(l.source, files, l.cfg)
+
+	if cacheable {
+		if cached, ok := l.cfg.cache.get(key); ok {
+			*migration = *cached
+			l.loaded[migration.ID] = true
+			return nil
+		}
+	}
+
+	for _, file := range files {
+		if err := l.loadFile(migration, file); err != nil {
+			return err
+		}
+	}
+
+	migration.baseline = false
+
+	if cacheable {
+		l.cfg.cache.put(key, migration)
+	}
+
+	l.loaded[migration.ID] = true
+
+	return nil
+}
+
+// readBaseline finds out whether a migration is a baseline without loading it. As the Baseline directive
+// must be in the header of a migration, only the header of its up file is read. Migrations that are cached,
+// as well as templates, single-file and Go migrations, are loaded instead.
+func (l *migrationLoader) readBaseline(migration *Migration) error {
+	if l.loaded[migration.ID] {
+		return nil
+	}
+
+	files := l.files[migration.ID]
+
+	if key, ok := l.cfg.cache.key(l.source, files, l.cfg); ok {
+		if _, ok := l.cfg.cache.get(key); ok {
+			return l.load(migration)
+		}
+	}
+
+	upFile := ""
+
+	for _, file := range files {
+		if _, directions, _, _ := parseMigrationFileName(file); len(directions) == 1 && directions[0] == "up" {
+			upFile = file
+		}
+	}
+
+	if !strings.HasSuffix(upFile, ".sql") {
+		return l.load(migration)
+	}
+
+	reader, err := l.source.GetMigrationFile(upFile)
+	if err != nil {
+		return fmt.Errorf("Error getting migrations: %s", err)
+	}
+
+	sr, err := parser.NewStatementReader(newVariableReader(reader, l.cfg), l.parseOptions(upFile)...)
+	if err != nil {
+		return fmt.Errorf("Error parsing migration %s: %s", migration.ID, err)
+	}
+
+	migration.baseline = sr.Baseline()
+
+	return nil
+}
+
+// parseOptions returns the options used to parse a file of the source.
+func (l *migrationLoader) parseOptions(file string) []parser.Option {
+	return []parser.Option{
+		parser.WithDialect(l.cfg.dialect),
+		parser.WithFileName(file),
+		parser.WithIncludeResolver(includeResolver(l.source, l.cfg)),
+		parser.WithDirectives(l.cfg.directives...),
+	}
+}
+
+func (l *migrationLoader) loadFile(migration *Migration, file string) error {
+	migrations, cfg, id := l.source, l.cfg, migration.ID
+
+	_, directions, _, _ := parseMigrationFileName(file)
+
+	parseOpts := l.parseOptions(file)
+
+	if len(directions) == 2 {
+		contents, err := readMigration(migrations, file, id, cfg)
+		if err != nil {
+			return err
+		}
+
+		up, down, err := parser.ParseUpDown(strings.NewReader(contents), parseOpts...)
+		if err != nil {
+			return fmt.Errorf("Error parsing migration %s: %s", id, err)
+		}
+
+		migration.Up = up
+		migration.Down = down
+
+		return nil
+	}
+
+	var (
+		parsed *parser.ParsedMigration
+		err    error
+	)
+
+	if cfg.stream && !strings.HasSuffix(file, templateExtension) && !strings.HasSuffix(file, ".go") {
+		parsed, err = streamMigration(migrations, file, cfg, parseOpts)
+	} else {
+		var contents string
+
+		contents, err = readMigration(migrations, file, id, cfg)
+		if err != nil {
+			return err
+		}
+
+		parsed, err = parser.Parse(strings.NewReader(contents), parseOpts...)
+	}
+
+	if err != nil {
+		return fmt.Errorf("Error parsing migration %s: %s", id, err)
+	}
+
+	var migrationFunc TxMigrationFunc
+
+	if goSource, ok := migrations.(goMigrationSource); ok && strings.HasSuffix(file, ".go") {
+		migrationFunc, err = goSource.goMigration(file)
+		if err != nil {
+			return fmt.Errorf("Error getting migration %s: %s", id, err)
+		}
+	}
+
+	if directions[0] == "up" {
+		migration.Up = parsed
+		migration.UpFunc = migrationFunc
+	} else {
+		migration.Down = parsed
+		migration.DownFunc = migrationFunc
+	}
+
+	return nil
+}
+
+// Migrations returns all migrations of the source, parsed as they would be for a driver of the given dialect.
+// It is intended for tools inspecting migrations, such as the lint package.
+func Migrations(migrations Source, dialect Dialect, opts ...Option) ([]*Migration, error) {
+	cfg := newConfig(opts)
+	cfg.dialect = dialect
+
+	return getMigrations(migrations, cfg)
+}
+
+// getMigrations lists and loads all migrations of the source.
+func getMigrations(migrations Source, cfg *config) ([]*Migration, error) {
+	loader, err := listMigrations(migrations, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, migration := range loader.migrations {
+		if err := loader.load(migration); err != nil {
+			return nil, err
+		}
+	}
+
+	return loader.migrations, nil
+}
+
+// streamMigration reads the header of a migration. The statements are read when the migration is executed,
+// but the whole migration is read once beforehand without keeping its statements, so that invalid
+// directives and variables fail the migration before its first statement runs.
+func streamMigration(migrations Source, file string, cfg *config, parseOpts []parser.Option) (*parser.ParsedMigration, error) {
+	open := func() (*parser.StatementReader, error) {
+		reader, err := migrations.GetMigrationFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		return parser.NewStatementReader(newVariableReader(reader, cfg), parseOpts...)
+	}
+
+	sr, err := open()
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		if _, _, err := sr.Next(); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, err
+		}
+	}
+
+	return &parser.ParsedMigration{
+		UseTransaction: sr.UseTransaction(),
+		Baseline:       sr.Baseline(),
+		Metadata:       sr.Metadata(),
+		Statements:     []string{},
+		Stream:         open,
+	}, nil
+}
+
+// readMigration returns the contents of a migration file, rendered and with variables substituted.
+func readMigration(migrations Source, file, id string, cfg *config) (string, error) {
+	reader, err := migrations.GetMigrationFile(file)
+	if err != nil {
+		return "", fmt.Errorf("Error getting migrations: %s", err)
+	}
+
+	contents, err := io.ReadAll(reader)
+	if err != nil {
+		return "", fmt.Errorf("Error getting migration content: %s", err)
 	}
 
-	for _, migration := range tempMigrations {
-		m = append(m, migration)
+	rendered, err := renderTemplate(file, string(contents), cfg)
+	if err != nil {
+		return "", fmt.Errorf("Error rendering migration %s: %s", id, err)
 	}
 
-	sort.Sort(byID(m))
+	substituted, err := substituteVariables(rendered, cfg)
+	if err != nil {
+		return "", fmt.Errorf("Error parsing migration %s: %s", id, err)
+	}
+
+	return substituted, nil
+}
+
+// includeResolver reads files included by migrations from the source. Included files are rendered and
+// substituted like migration files.
+func includeResolver(migrations Source, cfg *config) func(path string) (io.Reader, error) {
+	return func(path string) (io.Reader, error) {
+		reader, err := migrations.GetMigrationFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		contents, err := io.ReadAll(reader)
+		if err != nil {
+			return nil, err
+		}
+
+		rendered, err := renderTemplate(path, string(contents), cfg)
+		if err != nil {
+			return nil, err
+		}
 
-	return m, nil
+		substituted, err := substituteVariables(rendered, cfg)
+		if err != nil {
+			return nil, err
+		}
+
+		return strings.NewReader(substituted), nil
+	}
 }
 
-func planMigrations(migrations []*Migration, appliedMigrations []string, direction Direction, max int) []*PlannedMigration {
+func planMigrations(migrations []*Migration, appliedMigrations []string, direction Direction, max int) ([]*PlannedMigration, error) {
 	var applied []*Migration
 
 	for _, appliedMigration := range appliedMigrations {
@@ -224,7 +826,28 @@ func planMigrations(migrations []*Migration, appliedMigrations []string, directi
 		})
 	}
 
-	return result
+	// A baseline can only be applied to an empty database. Databases that are past the baseline
+	// skip it while catching up, but databases that stopped before it cannot be migrated up. They can
+	// still be migrated down.
+	if direction == Up && len(applied) > 0 {
+		for _, migration := range migrations {
+			if migration.isBaseline() && record.Less(migration) && !isApplied(applied, migration) {
+				return nil, fmt.Errorf("the database is at version %s, which is before the baseline migration %s: migrate the database to %s using the migrations replaced by the baseline first", record.ID, migration.ID, migration.ID)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+func isApplied(applied []*Migration, migration *Migration) bool {
+	for _, a := range applied {
+		if a.ID == migration.ID {
+			return true
+		}
+	}
+
+	return false
 }
 
 // Filter a slice of migrations into ones that should be applied.
@@ -273,7 +896,8 @@ func toCatchup(migrations, existingMigrations []*Migration, lastRun *Migration)
 			}
 		}
 
-		if !found && migration.Less(lastRun) {
+		// Baselines replace migrations that were already applied to the database.
+		if !found && migration.Less(lastRun) && !migration.isBaseline() {
 			missing = append(missing, &PlannedMigration{Migration: migration, Direction: Up})
 		}
 	}
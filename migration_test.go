@@ -1,8 +1,10 @@
 package migration
 
 import (
+	"io"
 	"reflect"
 	"sort"
+	"strings"
 	"testing"
 )
 
@@ -262,3 +264,376 @@ func TestMigrationWithError(t *testing.T) {
 		t.Errorf("No migrations should be applied, but %d was applied.", applied2)
 	}
 }
+
+func TestAtomicMigrationWithError(t *testing.T) {
+	memoryMigration := &MemoryMigrationSource{
+		Files: map[string]string{
+			"1_init.up.sql":     "",
+			"1_init.down.sql":   "",
+			"2_update.up.sql":   "",
+			"2_update.down.sql": "",
+			"3_fail.up.sql":     "error",
+			"3_fail.down.sql":   "",
+		},
+	}
+
+	drivers := map[string]Driver{
+		"compensating":  getMockDriver(),
+		"transactional": &mockTransactionalDriver{mockDriver: getMockDriver()},
+	}
+
+	for name, driver := range drivers {
+		applied, err := Migrate(driver, memoryMigration, Up, 0, WithAtomicRun())
+		if err == nil {
+			t.Errorf("Expected error while running migration with %s driver, but there was no error", name)
+		}
+		if applied != 0 {
+			t.Errorf("No migrations should be applied with %s driver, but %d was applied.", name, applied)
+		}
+
+		versions, err := driver.Versions()
+		if err != nil {
+			t.Fatalf("Unexpected error while getting versions: %s", err)
+		}
+		if len(versions) != 0 {
+			t.Errorf("Expected all migrations to be reverted with %s driver, got versions %v", name, versions)
+		}
+	}
+}
+
+func TestAtomicMigrationWithCompensationError(t *testing.T) {
+	memoryMigration := &MemoryMigrationSource{
+		Files: map[string]string{
+			"1_init.up.sql":     "",
+			"1_init.down.sql":   "",
+			"2_update.up.sql":   "",
+			"2_update.down.sql": "error",
+			"3_fail.up.sql":     "error",
+			"3_fail.down.sql":   "",
+		},
+	}
+
+	driver := getMockDriver()
+	applied, err := Migrate(driver, memoryMigration, Up, 0, WithAtomicRun())
+	if err == nil {
+		t.Fatal("Expected error while running migration, but there was no error")
+	}
+	if !strings.Contains(err.Error(), "error reverting the run") {
+		t.Errorf("Expected error to report the failed compensation, got %q", err)
+	}
+	if applied != 2 {
+		t.Errorf("%d migrations should still be applied, but %d was applied.", 2, applied)
+	}
+	if !reflect.DeepEqual(driver.applied, []string{"1_init", "2_update"}) {
+		t.Errorf("Expected migrations before the failed compensation to stay applied, got versions %v", driver.applied)
+	}
+}
+
+func TestSingleFileMigrations(t *testing.T) {
+	memoryMigration := &MemoryMigrationSource{
+		Files: map[string]string{
+			"1_init.up.sql":    "CREATE TABLE test_table1 (id integer);",
+			"1_init.down.sql":  "DROP TABLE test_table1;",
+			"2_add_index.sql":  "-- +migration Up\nCREATE INDEX idx ON test_table1 (id);\n-- +migration Down\nDROP INDEX idx;\n",
+			"3_no_down.sql":    "-- +migration Up\nCREATE TABLE test_table2 (id integer);\n",
+			"shared/audit.sql": "CREATE TRIGGER audit AFTER UPDATE ON test_table1 FOR EACH ROW EXECUTE FUNCTION audit();",
+		},
+	}
+
+	migrations, err := getMigrations(memoryMigration, newConfig(nil))
+	if err != nil {
+		t.Fatalf("Unexpected error while getting migrations: %s", err)
+	}
+
+	if len(migrations) != 3 {
+		t.Fatalf("Expected 3 migrations, got %d", len(migrations))
+	}
+
+	if !reflect.DeepEqual(migrations[1].Up.Statements, []string{"CREATE INDEX idx ON test_table1 (id);\n"}) {
+		t.Errorf("Unexpected up statements %q", migrations[1].Up.Statements)
+	}
+
+	if !reflect.DeepEqual(migrations[1].Down.Statements, []string{"DROP INDEX idx;\n"}) {
+		t.Errorf("Unexpected down statements %q", migrations[1].Down.Statements)
+	}
+
+	if migrations[2].Down != nil {
+		t.Error("Expected single file migration without a Down section to have no down migration")
+	}
+
+	memoryMigration.Files["2_add_index.down.sql"] = "DROP INDEX idx;"
+
+	if _, err := getMigrations(memoryMigration, newConfig(nil)); err == nil {
+		t.Error("Expected error for a migration defined by a single file and a down file, but there was no error")
+	}
+
+	delete(memoryMigration.Files, "2_add_index.down.sql")
+
+	// NoTransaction and Baseline apply to the section they are in, so they are rejected in the header instead
+	// of being dropped
+	memoryMigration.Files["2_add_index.sql"] = "-- +migration Up\n-- +migration NoTransaction\nCREATE INDEX CONCURRENTLY idx ON test_table1 (id);\n-- +migration Down\nDROP INDEX idx;\n"
+
+	migrations, err = getMigrations(memoryMigration, newConfig(nil))
+	if err != nil {
+		t.Fatalf("Unexpected error while getting migrations: %s", err)
+	}
+
+	if migrations[1].Up.UseTransaction || !migrations[1].Down.UseTransaction {
+		t.Error("Expected NoTransaction to apply to the up section only")
+	}
+
+	for _, directive := range []string{"NoTransaction", "Baseline"} {
+		memoryMigration.Files["2_add_index.sql"] = "-- +migration " + directive + "\n-- +migration Up\nCREATE INDEX idx ON test_table1 (id);\n"
+
+		driver := getMockDriver()
+
+		_, err := Migrate(driver, memoryMigration, Up, 0)
+		if err == nil || !strings.Contains(err.Error(), "2_add_index.sql:1: -- +migration NoTransaction and -- +migration Baseline must be in a -- +migration Up or -- +migration Down section") {
+			t.Errorf("Expected error for %s in the header of a single file migration, got %v", directive, err)
+		}
+
+		if len(driver.applied) != 0 {
+			t.Errorf("Expected no migrations to be applied with %s in the header, got %v", directive, driver.applied)
+		}
+	}
+}
+
+func TestDialectVariants(t *testing.T) {
+	memoryMigration := &MemoryMigrationSource{
+		Files: map[string]string{
+			"1_init.up.sql":          "CREATE TABLE test_table1 (id integer);",
+			"1_init.down.sql":        "DROP TABLE test_table1;",
+			"2_uuid.up.sql":          "ALTER TABLE test_table1 ADD COLUMN uuid char(36);",
+			"2_uuid.up.postgres.sql": "ALTER TABLE test_table1 ADD COLUMN uuid uuid;",
+			"2_uuid.up.sqlite.sql":   "ALTER TABLE test_table1 ADD COLUMN uuid text;",
+			"2_uuid.down.sql":        "ALTER TABLE test_table1 DROP COLUMN uuid;",
+			"3_index.postgres.sql":   "-- +migration Up\nCREATE INDEX CONCURRENTLY idx ON test_table1 (uuid);\n-- +migration Down\nDROP INDEX idx;\n",
+			"3_index.sql":            "-- +migration Up\nCREATE INDEX idx ON test_table1 (uuid);\n-- +migration Down\nDROP INDEX idx;\n",
+		},
+	}
+
+	expected := map[Dialect][]string{
+		Postgres: {"ALTER TABLE test_table1 ADD COLUMN uuid uuid;", "CREATE INDEX CONCURRENTLY idx ON test_table1 (uuid);\n"},
+		SQLite:   {"ALTER TABLE test_table1 ADD COLUMN uuid text;", "CREATE INDEX idx ON test_table1 (uuid);\n"},
+		MySQL:    {"ALTER TABLE test_table1 ADD COLUMN uuid char(36);", "CREATE INDEX idx ON test_table1 (uuid);\n"},
+	}
+
+	for dialect, statements := range expected {
+		cfg := newConfig(nil)
+		cfg.dialect = dialect
+
+		migrations, err := getMigrations(memoryMigration, cfg)
+		if err != nil {
+			t.Fatalf("Unexpected error while getting migrations for %s: %s", dialect, err)
+		}
+
+		if len(migrations) != 3 {
+			t.Fatalf("Expected variants to define 3 migrations for %s, got %d", dialect, len(migrations))
+		}
+
+		if got := []string{migrations[1].Up.Statements[0], migrations[2].Up.Statements[0]}; !reflect.DeepEqual(got, statements) {
+			t.Errorf("Expected statements %q for %s, got %q", statements, dialect, got)
+		}
+
+		if migrations[1].Down == nil {
+			t.Errorf("Expected generic down migration to be used for %s", dialect)
+		}
+	}
+
+	delete(memoryMigration.Files, "2_uuid.up.sql")
+
+	cfg := newConfig(nil)
+	cfg.dialect = MySQL
+
+	if _, err := getMigrations(memoryMigration, cfg); err == nil || !strings.Contains(err.Error(), "mysql dialect") {
+		t.Errorf("Expected error for a migration without a variant for the dialect, got %v", err)
+	}
+
+	memoryMigration.Files["2_uuid.postgres.sql"] = "-- +migration Up\nALTER TABLE test_table1 ADD COLUMN uuid uuid;\n"
+
+	cfg.dialect = Postgres
+
+	if _, err := getMigrations(memoryMigration, cfg); err == nil || !strings.Contains(err.Error(), "more than one file") {
+		t.Errorf("Expected error for a migration defined by more than one variant for a dialect, got %v", err)
+	}
+
+	for _, file := range []string{"4_uuid.up.oracle.sql", "4_uuid.oracle.sql", "4_uuid.down.oracle.sql.tmpl"} {
+		unknownDialect := &MemoryMigrationSource{
+			Files: map[string]string{
+				file: "-- +migration Up\nSELECT 1;\n",
+			},
+		}
+
+		if _, err := getMigrations(unknownDialect, cfg); err == nil || !strings.Contains(err.Error(), file+" is specific to the unknown dialect oracle") {
+			t.Errorf("Expected error for the unknown dialect of %s, got %v", file, err)
+		}
+	}
+}
+
+func TestMigrationsWithCustomDirectives(t *testing.T) {
+	memoryMigration := &MemoryMigrationSource{
+		Files: map[string]string{
+			"1_init.up.sql": "-- +migration Owner: team-a\nCREATE TABLE test_table1 (id integer);",
+		},
+	}
+
+	if _, err := getMigrations(memoryMigration, newConfig(nil)); err == nil {
+		t.Error("Expected error for an unknown directive, but there was no error")
+	}
+
+	if _, err := getMigrations(memoryMigration, newConfig([]Option{WithDirectives("Owner")})); err != nil {
+		t.Errorf("Unexpected error for an allowed custom directive: %s", err)
+	}
+}
+
+// countingSource counts the migration files read from a source.
+type countingSource struct {
+	Source
+	reads []string
+}
+
+func (c *countingSource) GetMigrationFile(file string) (io.Reader, error) {
+	c.reads = append(c.reads, file)
+	return c.Source.GetMigrationFile(file)
+}
+
+func TestMigrationsAreLoadedLazily(t *testing.T) {
+	source := &countingSource{
+		Source: &MemoryMigrationSource{
+			Files: map[string]string{
+				"1_init.up.sql":             "CREATE TABLE test (id integer);",
+				"1_init.down.sql":           "DROP TABLE test;",
+				"2_first_update.up.sql":     "ALTER TABLE test ADD COLUMN a integer;",
+				"2_first_update.down.sql":   "ALTER TABLE test DROP COLUMN a;",
+				"3_second_update.up.sql":    "ALTER TABLE test ADD COLUMN b integer;",
+				"3_second_update.down.sql":  "ALTER TABLE test DROP COLUMN b;",
+				"4_another_update.up.sql":   "ALTER TABLE test ADD COLUMN c integer;",
+				"4_another_update.down.sql": "ALTER TABLE test DROP COLUMN c;",
+			},
+		},
+	}
+
+	driver := getMockDriver()
+	cache := NewMigrationCache()
+
+	tests := []struct {
+		direction Direction
+		max       int
+		opts      []Option
+		reads     []string
+	}{
+		{Up, 2, nil, []string{"1_init.down.sql", "1_init.up.sql", "2_first_update.down.sql", "2_first_update.up.sql"}},
+		{Up, 1, nil, []string{"3_second_update.down.sql", "3_second_update.up.sql", "4_another_update.up.sql"}},
+		{Up, 0, nil, []string{"4_another_update.down.sql", "4_another_update.up.sql"}},
+		{Up, 0, nil, nil},
+		{Down, 1, []Option{WithMigrationCache(cache)}, []string{"4_another_update.down.sql", "4_another_update.up.sql"}},
+		{Up, 0, []Option{WithMigrationCache(cache)}, nil},
+	}
+
+	for i, test := range tests {
+		source.reads = nil
+
+		if _, err := Migrate(driver, source, test.direction, test.max, test.opts...); err != nil {
+			t.Fatalf("Unexpected error in run %d: %s", i, err)
+		}
+
+		sort.Strings(source.reads)
+
+		if !reflect.DeepEqual(source.reads, test.reads) {
+			t.Errorf("Expected run %d to read %v, read %v", i, test.reads, source.reads)
+		}
+	}
+
+	if len(driver.applied) != 4 {
+		t.Errorf("Expected %d migrations to be applied, got %v", 4, driver.applied)
+	}
+}
+
+func TestStreamedMigrationsAreValidatedBeforeRunning(t *testing.T) {
+	memoryMigration := &MemoryMigrationSource{
+		Files: map[string]string{
+			"1_init.up.sql":   "CREATE TABLE test (id integer);",
+			"2_update.up.sql": "-- +migration NoTransaction\nUPDATE test SET id = 1;\n\n-- +migration Unknown\nUPDATE test SET id = 2;\n",
+		},
+	}
+
+	driver := getMockDriver()
+
+	_, err := Migrate(driver, memoryMigration, Up, 0, WithStreaming())
+	if err == nil || !strings.Contains(err.Error(), "2_update.up.sql:4") {
+		t.Errorf("Expected an error for the directive on line 4 of the streamed migration, got %v", err)
+	}
+
+	if len(driver.applied) != 0 {
+		t.Errorf("Expected no migration to be applied before the invalid migration was read, got %v", driver.applied)
+	}
+}
+
+func TestBaselinesBeyondTheLimitAreReadFromHeaders(t *testing.T) {
+	source := &countingSource{
+		Source: &MemoryMigrationSource{
+			Files: map[string]string{
+				"1_init.up.sql":       "CREATE TABLE test (id integer);",
+				"2_update.up.sql":     "ALTER TABLE test ADD COLUMN a integer;",
+				"3_baseline.up.sql":   "-- +migration Baseline\nCREATE TABLE test (id integer, a integer);",
+				"3_baseline.down.sql": "DROP TABLE test;",
+			},
+		},
+	}
+
+	driver := getMockDriver()
+	driver.applied = []string{"1_init"}
+
+	_, err := Migrate(driver, source, Up, 1)
+	if err == nil || !strings.Contains(err.Error(), "before the baseline migration 3_baseline") {
+		t.Errorf("Expected an error for a database before the baseline, got %v", err)
+	}
+
+	sort.Strings(source.reads)
+
+	if expected := []string{"2_update.up.sql", "3_baseline.up.sql"}; !reflect.DeepEqual(source.reads, expected) {
+		t.Errorf("Expected %v to be read, read %v", expected, source.reads)
+	}
+}
+
+func TestMigrationCacheIsScopedToSourceAndOptions(t *testing.T) {
+	source := &MemoryMigrationSource{
+		Files: map[string]string{
+			"1_init.up.sql": "CREATE TABLE ${table} (id integer);",
+		},
+	}
+
+	otherSource := &MemoryMigrationSource{
+		Files: map[string]string{
+			"1_init.up.sql": "CREATE TABLE other (id integer);",
+		},
+	}
+
+	cache := NewMigrationCache()
+
+	tests := []struct {
+		source    Source
+		opts      []Option
+		statement string
+	}{
+		{source, []Option{WithVariables(map[string]string{"table": "first"})}, "CREATE TABLE first (id integer);"},
+		{source, []Option{WithVariables(map[string]string{"table": "second"})}, "CREATE TABLE second (id integer);"},
+		{otherSource, nil, "CREATE TABLE other (id integer);"},
+		{source, []Option{WithVariables(map[string]string{"table": "first"}), WithDirectives("Owner")}, "CREATE TABLE first (id integer);"},
+	}
+
+	for i, test := range tests {
+		migrations, err := Migrations(test.source, Postgres, append(test.opts, WithMigrationCache(cache))...)
+		if err != nil {
+			t.Fatalf("Unexpected error in run %d: %s", i, err)
+		}
+
+		if statements := migrations[0].Up.Statements; len(statements) != 1 || statements[0] != test.statement {
+			t.Errorf("Expected run %d to parse %q, got %q", i, test.statement, statements)
+		}
+	}
+
+	if len(cache.migrations) != len(tests) {
+		t.Errorf("Expected %d cached migrations, got %d", len(tests), len(cache.migrations))
+	}
+}
@@ -1,6 +1,7 @@
 package migration
 
 import (
+	"context"
 	"errors"
 	"strings"
 
@@ -16,6 +17,10 @@ func (m *mockDriver) Close() error {
 }
 
 func (m *mockDriver) Migrate(migration *PlannedMigration) error {
+	return m.MigrateContext(context.Background(), migration)
+}
+
+func (m *mockDriver) MigrateContext(ctx context.Context, migration *PlannedMigration) error {
 	var migrationStatements *parser.ParsedMigration
 
 	if migration.Direction == Up {
@@ -30,10 +35,16 @@ func (m *mockDriver) Migrate(migration *PlannedMigration) error {
 		errStatement = migrationStatements.Statements[0]
 	}
 
+	_, end := TraceStatement(ctx, migration, errStatement)
+
 	if strings.Contains(errStatement, "error") {
-		return errors.New("error executing migration")
+		err := errors.New("error executing migration")
+		end(nil, err)
+		return err
 	}
 
+	end(nil, nil)
+
 	versionIndex := -1
 
 	for i, version := range m.applied {
@@ -65,3 +76,36 @@ func getMockDriver() *mockDriver {
 		applied: []string{},
 	}
 }
+
+// mockTransactionalDriver applies the migrations of a transaction to a copy of the applied versions,
+// which replaces the applied versions of the driver on commit.
+type mockTransactionalDriver struct {
+	*mockDriver
+}
+
+func (m *mockTransactionalDriver) BeginTx(_ context.Context) (DriverTx, error) {
+	return &mockTx{
+		driver: m.mockDriver,
+		tx: &mockDriver{
+			applied: append([]string{}, m.applied...),
+		},
+	}, nil
+}
+
+type mockTx struct {
+	driver *mockDriver
+	tx     *mockDriver
+}
+
+func (m *mockTx) MigrateContext(ctx context.Context, migration *PlannedMigration) error {
+	return m.tx.MigrateContext(ctx, migration)
+}
+
+func (m *mockTx) Commit() error {
+	m.driver.applied = m.tx.applied
+	return nil
+}
+
+func (m *mockTx) Rollback() error {
+	return nil
+}
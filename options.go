@@ -0,0 +1,109 @@
+package migration
+
+import "text/template"
+
+// Option configures a migration run.
+type Option func(*config)
+
+type config struct {
+	tracer Tracer
+	atomic bool
+
+	// dialect is the dialect of the driver, used for splitting statements.
+	dialect Dialect
+
+	variables      map[string]string
+	variableLookup func(name string) (string, bool)
+
+	templateData  interface{}
+	templateFuncs template.FuncMap
+
+	directives []string
+
+	stream bool
+
+	cache *MigrationCache
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// WithTracer instruments the run using the given Tracer.
+func WithTracer(tracer Tracer) Option {
+	return func(c *config) {
+		c.tracer = tracer
+	}
+}
+
+// WithAtomicRun makes the run all-or-nothing. Drivers implementing TransactionalDriver apply all migrations
+// of the run within a single transaction. For other drivers, the migrations applied by the run are reverted
+// in the opposite direction when a migration fails.
+func WithAtomicRun() Option {
+	return func(c *config) {
+		c.atomic = true
+	}
+}
+
+// WithVariables replaces ${name} placeholders in migration files with the values of the variables before
+// the migrations are parsed. Placeholders of undefined variables cause the run to fail. Use $${name} for a
+// literal ${name}.
+func WithVariables(variables map[string]string) Option {
+	return func(c *config) {
+		c.variables = variables
+	}
+}
+
+// WithVariableLookup looks up variables that are not passed to WithVariables using the lookup function,
+// for example os.LookupEnv.
+func WithVariableLookup(lookup func(name string) (string, bool)) Option {
+	return func(c *config) {
+		c.variableLookup = lookup
+	}
+}
+
+// WithTemplateData sets the data used to render migration files ending with .tmpl, such as 1_init.up.sql.tmpl.
+func WithTemplateData(data interface{}) Option {
+	return func(c *config) {
+		c.templateData = data
+	}
+}
+
+// WithTemplateFuncs adds functions that can be called by migration files ending with .tmpl.
+func WithTemplateFuncs(funcs template.FuncMap) Option {
+	return func(c *config) {
+		c.templateFuncs = funcs
+	}
+}
+
+// WithDirectives allows custom "-- +migration Name" directives in migration files. Custom directives in the
+// header of a migration are added to its Metadata. Migrations containing other unknown directives are refused.
+func WithDirectives(names ...string) Option {
+	return func(c *config) {
+		c.directives = append(c.directives, names...)
+	}
+}
+
+// WithStreaming reads the statements of SQL migrations while they are executed, instead of reading all
+// migrations into memory before the run. Streamed migrations are split into statements, which are executed
+// one at a time, even if they are run within a transaction. Streamed files are validated by reading them
+// once before the run. Templates and single-file migrations are not streamed.
+func WithStreaming() Option {
+	return func(c *config) {
+		c.stream = true
+	}
+}
+
+// WithMigrationCache keeps the migrations parsed by the run in the given cache, and reuses the migrations
+// cached by earlier runs with the same source and options instead of reading their files again.
+func WithMigrationCache(cache *MigrationCache) Option {
+	return func(c *config) {
+		c.cache = cache
+	}
+}
@@ -0,0 +1,378 @@
+package parser
+
+import "strings"
+
+// Dialect identifies the SQL dialect of a database.
+type Dialect string
+
+// Constants for dialects
+const (
+	Postgres Dialect = "postgres"
+	MySQL    Dialect = "mysql"
+	SQLite   Dialect = "sqlite"
+	Phoenix  Dialect = "phoenix"
+)
+
+// lexicalRules describes the quoting and comment syntax of a dialect.
+type lexicalRules struct {
+	// Backticks quote identifiers.
+	backtickQuotes bool
+
+	// Square brackets quote identifiers.
+	bracketQuotes bool
+
+	// Backslashes escape characters in single and double quoted strings.
+	backslashEscapes bool
+
+	// Backslashes escape characters in single quoted strings prefixed with E.
+	escapeStrings bool
+
+	// $tag$ starts a string ending with the next occurrence of $tag$.
+	dollarQuotes bool
+
+	// Block comments can be nested.
+	nestedComments bool
+
+	// # starts a comment. MySQL also requires -- comments to be followed by whitespace.
+	hashComments bool
+
+	// Comments starting with /*! contain code that is executed by MySQL, such as the
+	// version-specific statements written by mysqldump.
+	executableComments bool
+}
+
+// The default rules are used if the dialect is unknown. They understand the quoting syntax
+// of all dialects that does not conflict with standard SQL.
+var defaultLexicalRules = lexicalRules{
+	backtickQuotes: true,
+	dollarQuotes:   true,
+}
+
+var dialectLexicalRules = map[Dialect]lexicalRules{
+	Postgres: {
+		escapeStrings:  true,
+		dollarQuotes:   true,
+		nestedComments: true,
+	},
+	MySQL: {
+		backtickQuotes:     true,
+		backslashEscapes:   true,
+		hashComments:       true,
+		executableComments: true,
+	},
+	SQLite: {
+		backtickQuotes: true,
+		bracketQuotes:  true,
+	},
+	Phoenix: {},
+}
+
+func rulesFor(dialect Dialect) lexicalRules {
+	if rules, ok := dialectLexicalRules[dialect]; ok {
+		return rules
+	}
+
+	return defaultLexicalRules
+}
+
+// splitStatements splits sql after each delimiter that is not part of a string, a quoted identifier or
+// a comment. Concatenating the statements returns sql. Trailing whitespace is added to the last statement.
+func splitStatements(sql string, dialect Dialect, delimiter string) []string {
+	rules := rulesFor(dialect)
+
+	var statements []string
+
+	start := 0
+
+	for i := 0; i < len(sql); {
+		if strings.HasPrefix(sql[i:], delimiter) {
+			i += len(delimiter)
+			statements = append(statements, sql[start:i])
+			start = i
+			continue
+		}
+
+		i = rules.skipToken(sql, i)
+	}
+
+	if start < len(sql) || len(statements) == 0 {
+		if strings.TrimSpace(sql[start:]) == "" && len(statements) > 0 {
+			statements[len(statements)-1] += sql[start:]
+		} else {
+			statements = append(statements, sql[start:])
+		}
+	}
+
+	return statements
+}
+
+// isEmptyStatement returns true if the statement only contains whitespace and comments.
+func isEmptyStatement(statement string, dialect Dialect) bool {
+	rules := rulesFor(dialect)
+
+	for i := 0; i < len(statement); {
+		if rules.executableComments && strings.HasPrefix(statement[i:], "/*!") {
+			return false
+		}
+
+		if end, ok := rules.skipComment(statement, i); ok {
+			i = end
+			continue
+		}
+
+		if !isSpace(statement[i]) {
+			return false
+		}
+
+		i++
+	}
+
+	return true
+}
+
+// skipToken returns the position after the string, quoted identifier, comment or character at position i.
+// Unterminated strings, quoted identifiers and comments extend to the end of sql.
+func (r *lexicalRules) skipToken(sql string, i int) int {
+	if end, ok := r.skipComment(sql, i); ok {
+		return end
+	}
+
+	if t, content, ok := r.openToken(sql, i); ok {
+		if end, ok := t.scan(sql, content, true); ok {
+			return end
+		}
+		return len(sql)
+	}
+
+	return i + 1
+}
+
+// skipComment returns the position after the comment starting at position i, if there is one.
+func (r *lexicalRules) skipComment(sql string, i int) (int, bool) {
+	switch {
+	case strings.HasPrefix(sql[i:], "--") && (!r.hashComments || i+2 == len(sql) || isSpace(sql[i+2])),
+		sql[i] == '#' && r.hashComments:
+		if end := strings.IndexByte(sql[i:], '\n'); end >= 0 {
+			return i + end + 1, true
+		}
+		return len(sql), true
+
+	case strings.HasPrefix(sql[i:], "/*"):
+		t := &token{comment: true, nested: r.nestedComments}
+		if end, ok := t.scan(sql, i, true); ok {
+			return end, true
+		}
+		return len(sql), true
+	}
+
+	return i, false
+}
+
+// token is the state of a string, quoted identifier or block comment that is being scanned.
+type token struct {
+	// closing ends a quoted string or identifier.
+	closing byte
+
+	// Backslashes escape characters in the quoted string.
+	escapes bool
+
+	// tag ends a dollar quoted string.
+	tag string
+
+	// comment is set for block comments, which end when depth goes back to zero.
+	comment bool
+	nested  bool
+	depth   int
+}
+
+// openToken returns the string, quoted identifier or block comment starting at position i, and the
+// position where the scan of its content starts.
+func (r *lexicalRules) openToken(sql string, i int) (*token, int, bool) {
+	switch c := sql[i]; {
+	case strings.HasPrefix(sql[i:], "/*"):
+		return &token{comment: true, nested: r.nestedComments}, i, true
+	case c == '\'':
+		escapes := r.backslashEscapes || (r.escapeStrings && i > 0 && (sql[i-1] == 'E' || sql[i-1] == 'e') && (i == 1 || !isIdentifierChar(sql[i-2])))
+		return &token{closing: '\'', escapes: escapes}, i + 1, true
+	case c == '"':
+		return &token{closing: '"', escapes: r.backslashEscapes}, i + 1, true
+	case c == '`' && r.backtickQuotes:
+		return &token{closing: '`'}, i + 1, true
+	case c == '[' && r.bracketQuotes:
+		return &token{closing: ']'}, i + 1, true
+	case c == '$' && r.dollarQuotes:
+		if tag, ok := dollarTag(sql, i); ok {
+			return &token{tag: tag}, i + len(tag), true
+		}
+	}
+
+	return nil, i, false
+}
+
+// scan scans the token from position i and returns the position after its end. If the token does not
+// end in sql, scan returns false and the position where the scan resumes once more input is available.
+// Unless atEOF is set, a closing quote at the end of sql is not trusted, as it may be doubled by the
+// next input.
+func (t *token) scan(sql string, i int, atEOF bool) (int, bool) {
+	switch {
+	case t.comment:
+		for j := i; j < len(sql); j++ {
+			if j+1 == len(sql) {
+				return j, false
+			}
+
+			switch {
+			case sql[j] == '/' && sql[j+1] == '*' && (t.depth == 0 || t.nested):
+				t.depth++
+				j++
+			case sql[j] == '*' && sql[j+1] == '/':
+				t.depth--
+				j++
+
+				if t.depth == 0 {
+					return j + 1, true
+				}
+			}
+		}
+
+		return len(sql), false
+
+	case t.tag != "":
+		if end := strings.Index(sql[i:], t.tag); end >= 0 {
+			return i + end + len(t.tag), true
+		}
+
+		// The closing tag may start in the last characters.
+		if resume := len(sql) - len(t.tag) + 1; resume > i {
+			return resume, false
+		}
+		return i, false
+	}
+
+	for j := i; j < len(sql); j++ {
+		switch sql[j] {
+		case '\\':
+			if t.escapes {
+				if j+1 == len(sql) {
+					return j, false
+				}
+				j++
+			}
+		case t.closing:
+			if j+1 == len(sql) && !atEOF {
+				return j, false
+			}
+			if j+1 < len(sql) && sql[j+1] == t.closing {
+				j++
+				continue
+			}
+			return j + 1, true
+		}
+	}
+
+	return len(sql), false
+}
+
+// dollarTag returns the tag of the dollar quote starting at position i, such as $$ or $body$.
+func dollarTag(sql string, i int) (string, bool) {
+	// Dollar signs can be part of identifiers.
+	if i > 0 && isIdentifierChar(sql[i-1]) {
+		return "", false
+	}
+
+	for j := i + 1; j < len(sql); j++ {
+		switch c := sql[j]; {
+		case c == '$':
+			return sql[i : j+1], true
+		case c >= '0' && c <= '9':
+			// Positional parameters such as $1 are not dollar quotes.
+			if j == i+1 {
+				return "", false
+			}
+		case !isIdentifierChar(c):
+			return "", false
+		}
+	}
+
+	return "", false
+}
+
+func isIdentifierChar(c byte) bool {
+	return c == '_' || c == '$' || c >= 0x80 || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f' || c == '\v'
+}
+
+// splitter finds the end of the first statement in a buffer that grows line by line. Tokens that have
+// been scanned are not scanned again when the buffer grows, including the part of a string or comment
+// that continues in the next lines.
+type splitter struct {
+	pos int
+
+	// open is the token that continues past pos.
+	open *token
+}
+
+// split returns the position after the delimiter ending the first statement in buf, or -1 if buf does
+// not contain a complete statement yet. The buffer must not be changed before the statement is removed.
+func (s *splitter) split(buf []byte, rules *lexicalRules, delimiter string) int {
+	start := s.pos
+	i := 0
+
+	if s.open == nil {
+		// Tokens look back at most two characters, such as the E of E'\n' strings.
+		start -= 2
+		if start < 0 {
+			start = 0
+		}
+		i = s.pos - start
+	}
+
+	window := string(buf[start:])
+
+	if s.open != nil {
+		end, ok := s.open.scan(window, 0, false)
+		if !ok {
+			s.pos = start + end
+			return -1
+		}
+
+		s.open = nil
+		i = end
+	}
+
+	for i < len(window) {
+		if strings.HasPrefix(window[i:], delimiter) {
+			s.pos = 0
+			return start + i + len(delimiter)
+		}
+
+		if t, content, ok := rules.openToken(window, i); ok {
+			end, ok := t.scan(window, content, false)
+			if !ok {
+				s.open = t
+				s.pos = start + end
+				return -1
+			}
+
+			i = end
+			continue
+		}
+
+		next := rules.skipToken(window, i)
+
+		// Line comments and characters that may start a token are scanned again with the next line.
+		if next >= len(window) {
+			s.pos = start + i
+			return -1
+		}
+
+		i = next
+	}
+
+	s.pos = len(buf)
+
+	return -1
+}
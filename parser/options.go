@@ -0,0 +1,71 @@
+package parser
+
+import "io"
+
+// Option configures the parsing of a migration.
+type Option func(*config)
+
+type config struct {
+	dialect  Dialect
+	fileName string
+	resolve  func(path string) (io.Reader, error)
+
+	// lineOffset is the number of lines preceding the migration in its file.
+	lineOffset int
+
+	directives map[string]struct{}
+}
+
+func newConfig(opts []Option) *config {
+	c := &config{}
+
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// WithDialect splits statements according to the quoting and comment syntax of the dialect. If no
+// dialect is given, statements are split according to syntax shared by most dialects.
+func WithDialect(dialect Dialect) Option {
+	return func(c *config) {
+		c.dialect = dialect
+	}
+}
+
+// WithFileName sets the name of the file containing the migration.
+func WithFileName(name string) Option {
+	return func(c *config) {
+		c.fileName = name
+	}
+}
+
+// WithIncludeResolver enables the "-- +migration Include: path" directive, which inlines the file at path
+// before the statements are split. The resolver returns the contents of included files. Files must not
+// include themselves, directly or indirectly.
+func WithIncludeResolver(resolve func(path string) (io.Reader, error)) Option {
+	return func(c *config) {
+		c.resolve = resolve
+	}
+}
+
+// WithDirectives allows custom "-- +migration Name" directives. Custom directives in the header of a migration
+// are added to its Metadata, and other custom directives are ignored. Other unknown directives are errors.
+func WithDirectives(names ...string) Option {
+	return func(c *config) {
+		if c.directives == nil {
+			c.directives = map[string]struct{}{}
+		}
+
+		for _, name := range names {
+			c.directives[name] = struct{}{}
+		}
+	}
+}
+
+func withLineOffset(lineOffset int) Option {
+	return func(c *config) {
+		c.lineOffset = lineOffset
+	}
+}
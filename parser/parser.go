@@ -7,116 +7,847 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 )
 
 const (
 	sqlCmdPrefix         = "-- +migration "
 	optionNoTransaction  = "NoTransaction"
+	optionBaseline       = "Baseline"
+	optionTODO           = "TODO"
+	optionInclude        = "Include"
+	optionUp             = "Up"
+	optionDown           = "Down"
 	optionBeginStatement = "BeginStatement"
 	optionEndStatement   = "EndStatement"
+	optionIgnoreError    = "IgnoreError"
+	optionRetry          = "Retry"
+	optionDescription    = "Description"
+	optionAllow          = "Allow"
+	defaultDelimiter     = ";"
 )
 
+// delimiterRegex matches the DELIMITER command of the MySQL client, which changes the delimiter of statements.
+var delimiterRegex = regexp.MustCompile(`(?i)^DELIMITER\s+(\S+)$`)
+
 // ParsedMigration is a parsed migration
 type ParsedMigration struct {
 	UseTransaction bool
 	Statements     []string
+
+	// Positions contains the position of each statement in the migration files. It is empty if the
+	// positions are unknown.
+	Positions []Position
+
+	// Options contains the options of each statement set by the IgnoreError, Retry and Allow directives. It is
+	// empty if no statement has options, and statements after the last one with options may be missing.
+	Options []StatementOptions
+
+	// Baseline is set for migrations replacing all migrations up to and including its ID.
+	Baseline bool
+
+	// Metadata contains the Description and the custom directives allowed using WithDirectives that are in
+	// the header of the migration, by name. Custom directives without an argument have an empty value.
+	Metadata map[string]string
+
+	// Stream is set for migrations that are read incrementally, instead of Statements and Positions.
+	// It opens a StatementReader for the migration.
+	Stream func() (*StatementReader, error)
+}
+
+// Position is the position of the first line of a statement.
+type Position struct {
+	File string
+	Line int
+}
+
+// String returns the position formatted as file:line.
+func (p Position) String() string {
+	if p.File == "" {
+		return fmt.Sprintf("line %d", p.Line)
+	}
+
+	return fmt.Sprintf("%s:%d", p.File, p.Line)
+}
+
+// IsValid returns true if the position is known.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+// StatementOptions are the options of a statement set by the directives preceding it.
+type StatementOptions struct {
+	// IgnoreError matches the errors of the statement that are ignored. It is set by the IgnoreError directive.
+	IgnoreError *regexp.Regexp
+
+	// Retries is the number of times the statement is retried if it fails, waiting RetryDelay before each
+	// retry. They are set by the Retry directive.
+	Retries    int
+	RetryDelay time.Duration
+
+	// Allow contains the names of the lint rules that are allowed to report the statement. It is set by the
+	// Allow directive.
+	Allow []string
+}
+
+// IsZero returns true if no options are set.
+func (o StatementOptions) IsZero() bool {
+	return !o.HandlesErrors() && len(o.Allow) == 0
+}
+
+// HandlesErrors returns true if errors of the statement are ignored or retried.
+func (o StatementOptions) HandlesErrors() bool {
+	return o.IgnoreError != nil || o.Retries > 0
+}
+
+// StatementPosition returns the position of the ith statement. The position is not valid if it is unknown.
+func (p *ParsedMigration) StatementPosition(i int) Position {
+	if i < len(p.Positions) {
+		return p.Positions[i]
+	}
+
+	return Position{}
+}
+
+// StatementOptions returns the options of the ith statement.
+func (p *ParsedMigration) StatementOptions(i int) StatementOptions {
+	if i < len(p.Options) {
+		return p.Options[i]
+	}
+
+	return StatementOptions{}
+}
+
+// Identifier is a regular expression matching an identifier that is unquoted or quoted using double quotes,
+// backticks or square brackets.
+const Identifier = "(?:\"[^\"]+\"|`[^`]+`|\\[[^\\]]+\\]|[\\w$]+)"
+
+// Name is a regular expression matching an identifier that is optionally qualified by a schema.
+const Name = Identifier + "(?:\\." + Identifier + ")?"
+
+// proceduralRegex matches statements creating functions, procedures and triggers.
+var proceduralRegex = regexp.MustCompile(`(?is)^CREATE\s+(?:OR\s+REPLACE\s+)?(?:DEFINER\s*=\s*\S+\s+)?(?:FUNCTION|PROCEDURE|TRIGGER)\s`)
+
+// IsProcedural returns true if a statement, without leading comments, creates a function, procedure or
+// trigger. The bodies of such statements contain procedural code, so they cannot be split into statements.
+func IsProcedural(statement string) bool {
+	return proceduralRegex.MatchString(statement)
 }
 
-func splitStatementsBySemicolon(buf string) []string {
-	statements := strings.SplitAfter(buf, ";")
+// StripComments removes leading comment lines and surrounding whitespace from a statement.
+func StripComments(statement string) string {
+	statement = strings.TrimSpace(statement)
 
-	for i, statement := range statements {
-		trimmed := strings.TrimSpace(statement)
-		if trimmed == "" && i > 0 {
-			statements[i-1] += statements[i]
-			statements = statements[:i+copy(statements[i:], statements[i+1:])]
+	for strings.HasPrefix(statement, "--") {
+		i := strings.Index(statement, "\n")
+		if i == -1 {
+			return ""
+		}
+		statement = strings.TrimSpace(statement[i+1:])
+	}
+
+	return statement
+}
+
+// SplitStatements splits SQL into its statements. Semicolons within strings, quoted identifiers
+// and comments do not end a statement. Whitespace surrounding the statements is removed and
+// statements containing only comments are skipped.
+func SplitStatements(sql string, opts ...Option) []string {
+	cfg := newConfig(opts)
+
+	var statements []string
+
+	for _, statement := range splitStatements(sql, cfg.dialect, defaultDelimiter) {
+		if !isEmptyStatement(statement, cfg.dialect) {
+			statements = append(statements, strings.TrimSpace(statement))
 		}
 	}
 
 	return statements
 }
 
+// ForEachStatement calls fn for each statement of the migration, its position and its options. Streamed
+// migrations are read one statement at a time.
+func (p *ParsedMigration) ForEachStatement(fn func(statement string, position Position, options StatementOptions) error) error {
+	if p.Stream == nil {
+		for i, statement := range p.Statements {
+			if err := fn(statement, p.StatementPosition(i), p.StatementOptions(i)); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	sr, err := p.Stream()
+	if err != nil {
+		return err
+	}
+
+	for {
+		statement, position, err := sr.Next()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if err := fn(statement, position, sr.Options()); err != nil {
+			return err
+		}
+	}
+}
+
+// Error is an error at a position in a migration file.
+type Error struct {
+	Position Position
+	Message  string
+}
+
+func newError(position Position, format string, args ...interface{}) *Error {
+	return &Error{
+		Position: position,
+		Message:  fmt.Sprintf(format, args...),
+	}
+}
+
+// Error returns the message prefixed with the position.
+func (e *Error) Error() string {
+	return e.Position.String() + ": " + e.Message
+}
+
+// splitDirective splits a directive such as "Include: shared/audit_trigger.sql" into its name and argument.
+func splitDirective(directive string) (name, argument string) {
+	i := strings.IndexAny(directive, ": \t")
+	if i == -1 {
+		return directive, ""
+	}
+
+	return directive[:i], strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(directive[i:]), ":"))
+}
+
 // Parse reads a migration and returns a parsed migrations
-func Parse(r io.Reader) (*ParsedMigration, error) {
-	p := &ParsedMigration{
-		UseTransaction: true,
-		Statements:     []string{},
+func Parse(r io.Reader, opts ...Option) (*ParsedMigration, error) {
+	mp := newMigrationParser(r, opts, false)
+
+	for {
+		more, err := mp.next()
+		if err != nil {
+			return mp.p, err
+		}
+
+		if !more {
+			break
+		}
 	}
 
-	var buf bytes.Buffer
+	if err := mp.finish(); err != nil {
+		return mp.p, err
+	}
 
-	scanner := bufio.NewScanner(r)
-	scanner.Split(scanLines)
+	return mp.p, nil
+}
 
-	isFirstLine := true
+// ParseUpDown reads a migration file containing both the up and the down migration. The migrations
+// are in sections starting with "-- +migration Up" and "-- +migration Down", which are parsed like
+// separate files. The down migration is nil if there is no Down section. Only comments and metadata
+// directives may precede the first section.
+func ParseUpDown(r io.Reader, opts ...Option) (up, down *ParsedMigration, err error) {
+	cfg := newConfig(opts)
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		trimmed := strings.TrimSpace(line)
+	type section struct {
+		content    strings.Builder
+		lineOffset int
+	}
 
-		if strings.HasPrefix(trimmed, sqlCmdPrefix) {
-			option := strings.Replace(trimmed, sqlCmdPrefix, "", -1)
+	var (
+		header      strings.Builder
+		headerStart int
+		current     *section
+		sections    = map[string]*section{}
+	)
 
-			switch option {
-			case optionNoTransaction:
-				if !isFirstLine && buf.Len() > 0 {
-					return p, fmt.Errorf("%s%s must be in the first line of the migration", sqlCmdPrefix, optionNoTransaction)
-				}
-				p.UseTransaction = false
+	reader := bufio.NewReader(r)
 
-			case optionBeginStatement:
-				// Add lines encountered before beginning the statement
-				withoutCR := string(dropCR(buf.Bytes()))
+	lineNumber := 0
 
-				if !p.UseTransaction {
-					p.Statements = append(p.Statements, splitStatementsBySemicolon(withoutCR)...)
-				} else {
-					p.Statements = append(p.Statements, withoutCR)
-				}
-				buf.Reset()
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, nil, err
+		}
+
+		if line == "" {
+			break
+		}
+
+		lineNumber++
+
+		if trimmed := strings.TrimSpace(line); trimmed == sqlCmdPrefix+optionUp || trimmed == sqlCmdPrefix+optionDown {
+			name := strings.TrimPrefix(trimmed, sqlCmdPrefix)
+
+			if _, ok := sections[name]; ok {
+				return nil, nil, newError(Position{File: cfg.fileName, Line: lineNumber}, "%s%s must only appear once in the migration", sqlCmdPrefix, name)
+			}
+
+			current = &section{lineOffset: lineNumber}
+			sections[name] = current
+			continue
+		}
+
+		if current == nil {
+			if headerStart == 0 && strings.TrimSpace(line) != "" {
+				headerStart = lineNumber
+			}
+
+			header.WriteString(line)
+		} else {
+			current.content.WriteString(line)
+		}
+	}
+
+	// Metadata in the header applies to both sections
+	headerMigration, err := parseHeader(header.String(), headerStart, cfg, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	upSection, ok := sections[optionUp]
+	if !ok {
+		return nil, nil, fmt.Errorf("migration does not contain a %s%s section", sqlCmdPrefix, optionUp)
+	}
+
+	up, err = Parse(strings.NewReader(upSection.content.String()), append(opts, withLineOffset(upSection.lineOffset))...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	up.Metadata = mergeMetadata(headerMigration.Metadata, up.Metadata)
+
+	if downSection, ok := sections[optionDown]; ok {
+		down, err = Parse(strings.NewReader(downSection.content.String()), append(opts, withLineOffset(downSection.lineOffset))...)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		down.Metadata = mergeMetadata(headerMigration.Metadata, down.Metadata)
+	}
+
+	return up, down, nil
+}
+
+// parseHeader validates the lines before the first section of a migration containing an up and a down
+// migration. The header may only contain comments and metadata directives, as SQL and directives such as
+// NoTransaction must apply to a single section. Unknown directives are reported like in sections.
+func parseHeader(header string, headerStart int, cfg *config, opts []Option) (*ParsedMigration, error) {
+	if !isEmptyStatement(header, cfg.dialect) {
+		return nil, newError(Position{File: cfg.fileName, Line: headerStart}, "SQL must be in a %s%s or %s%s section", sqlCmdPrefix, optionUp, sqlCmdPrefix, optionDown)
+	}
+
+	parsed, err := Parse(strings.NewReader(header), opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	if !parsed.UseTransaction || parsed.Baseline {
+		return nil, newError(Position{File: cfg.fileName, Line: headerStart}, "%s%s and %s%s must be in a %s%s or %s%s section", sqlCmdPrefix, optionNoTransaction, sqlCmdPrefix, optionBaseline, sqlCmdPrefix, optionUp, sqlCmdPrefix, optionDown)
+	}
+
+	return parsed, nil
+}
+
+// mergeMetadata returns the metadata of a section combined with the metadata of the header. The metadata of
+// the section takes precedence.
+func mergeMetadata(header, section map[string]string) map[string]string {
+	if len(header) == 0 {
+		return section
+	}
+
+	merged := make(map[string]string, len(header)+len(section))
+
+	for name, value := range header {
+		merged[name] = value
+	}
+
+	for name, value := range section {
+		merged[name] = value
+	}
+
+	return merged
+}
+
+// migrationParser holds the state of parsing a migration and the files it includes. The migration is
+// parsed line by line, so that statements can be read before the whole migration has been parsed.
+type migrationParser struct {
+	cfg   *config
+	rules lexicalRules
+	p     *ParsedMigration
+	buf   bytes.Buffer
+
+	isFirstLine bool
+	delimiter   string
+
+	// sources is the chain of files being read, starting with the migration itself.
+	sources []*lineSource
+
+	// lines contains the offset and position of each line in the buffer.
+	lines []bufferedLine
+
+	// statementBlock is the position of the BeginStatement of the current statement block.
+	statementBlock Position
+
+	// statementCount is the number of statements added to the migration.
+	statementCount int
+
+	// options are the options set by the directives preceding the next statement, which were set at
+	// optionsPosition.
+	options         StatementOptions
+	optionsPosition Position
+
+	// If stream is set, statements are split as soon as they are complete, including statements
+	// of migrations run within a transaction.
+	stream   bool
+	splitter splitter
+}
+
+type lineSource struct {
+	r    *bufio.Reader
+	file string
+	line int
+}
+
+type bufferedLine struct {
+	offset   int
+	position Position
+}
+
+func newMigrationParser(r io.Reader, opts []Option, stream bool) *migrationParser {
+	cfg := newConfig(opts)
+
+	return &migrationParser{
+		cfg:   cfg,
+		rules: rulesFor(cfg.dialect),
+		p: &ParsedMigration{
+			UseTransaction: true,
+			Statements:     []string{},
+			Positions:      []Position{},
+		},
+		isFirstLine: true,
+		delimiter:   defaultDelimiter,
+		sources: []*lineSource{
+			{
+				r:    bufio.NewReader(r),
+				file: cfg.fileName,
+				line: cfg.lineOffset,
+			},
+		},
+		stream: stream,
+	}
+}
+
+// next parses the next line of the migration. It returns false if there are no more lines.
+func (mp *migrationParser) next() (bool, error) {
+	if len(mp.sources) == 0 {
+		return false, nil
+	}
+
+	source := mp.sources[len(mp.sources)-1]
+
+	line, err := source.r.ReadString('\n')
+	if err != nil && err != io.EOF {
+		return false, err
+	}
+
+	if line == "" {
+		mp.sources = mp.sources[:len(mp.sources)-1]
+		return len(mp.sources) > 0, nil
+	}
+
+	source.line++
+	trimmed := strings.TrimSpace(line)
+
+	if matches := delimiterRegex.FindStringSubmatch(trimmed); matches != nil && mp.honoursDelimiter() {
+		// Add lines encountered before changing the delimiter
+		mp.flush()
+
+		mp.delimiter = matches[1]
+	} else if strings.HasPrefix(trimmed, sqlCmdPrefix) {
+		position := Position{File: source.file, Line: source.line}
+		name, argument := splitDirective(strings.TrimPrefix(trimmed, sqlCmdPrefix))
 
-			case optionEndStatement:
-				// Add the lines encountered during a statement block as 1 block
-				p.Statements = append(p.Statements, string(dropCR(buf.Bytes())))
+		switch name {
+		case optionTODO:
+			// TODOs mark migrations that need to be completed by a human before they can be run
+			return false, newError(position, "migration contains an unresolved %s", trimmed)
 
-				buf.Reset()
+		case optionInclude:
+			if argument == "" {
+				return false, newError(position, "%s%s requires the path of the included file", sqlCmdPrefix, optionInclude)
 			}
-		} else if _, err := buf.WriteString(line); err != nil {
-			return p, errors.New("error writing line to buffer")
+
+			if err := mp.include(position, argument); err != nil {
+				return false, err
+			}
+
+		case optionNoTransaction, optionBaseline, optionBeginStatement, optionEndStatement:
+			if argument != "" {
+				return false, newError(position, "%s%s does not take arguments", sqlCmdPrefix, name)
+			}
+
+			if err := mp.directive(position, name); err != nil {
+				return false, err
+			}
+
+		case optionIgnoreError, optionRetry, optionAllow:
+			if err := mp.statementDirective(position, name, argument); err != nil {
+				return false, err
+			}
+
+		case optionDescription:
+			if argument == "" {
+				return false, newError(position, "%s%s requires an argument", sqlCmdPrefix, optionDescription)
+			}
+
+			if mp.afterSQL() {
+				return false, newError(position, "%s%s must be in the header of the migration", sqlCmdPrefix, optionDescription)
+			}
+
+			if err := mp.metadata(position, name, argument); err != nil {
+				return false, err
+			}
+
+		default:
+			if _, ok := mp.cfg.directives[name]; !ok {
+				return false, newError(position, "unknown directive %s%s", sqlCmdPrefix, name)
+			}
+
+			// Custom directives after SQL are ignored
+			if !mp.afterSQL() {
+				if err := mp.metadata(position, name, argument); err != nil {
+					return false, err
+				}
+			}
+		}
+	} else {
+		// Included files may not end with a newline
+		if len(mp.sources) > 1 && !strings.HasSuffix(line, "\n") {
+			line += "\n"
+		}
+
+		mp.lines = append(mp.lines, bufferedLine{
+			offset:   mp.buf.Len(),
+			position: Position{File: source.file, Line: source.line},
+		})
+
+		if _, err := mp.buf.WriteString(line); err != nil {
+			return false, errors.New("error writing line to buffer")
+		}
+
+		if (mp.stream || mp.optionsPosition.IsValid()) && !mp.statementBlock.IsValid() {
+			mp.addCompleteStatements()
 		}
+	}
 
-		isFirstLine = false
+	mp.isFirstLine = false
+
+	return true, nil
+}
+
+// finish adds the remaining lines to the migration after all lines have been parsed.
+func (mp *migrationParser) finish() error {
+	if mp.statementBlock.IsValid() {
+		return newError(mp.statementBlock, "%s%s is not terminated by %s%s", sqlCmdPrefix, optionBeginStatement, sqlCmdPrefix, optionEndStatement)
 	}
 
 	// If the buffer contains lines, process them
-	if buf.Len() > 0 && strings.TrimSpace(buf.String()) != "" {
-		withoutCR := string(dropCR(buf.Bytes()))
+	mp.flush()
+
+	if mp.optionsPosition.IsValid() {
+		return newError(mp.optionsPosition, "%s%s is not followed by a statement", sqlCmdPrefix, mp.optionsDirective())
+	}
+
+	return nil
+}
+
+// honoursDelimiter returns true if DELIMITER lines change the delimiter. DELIMITER is a command of the MySQL
+// client, so other dialects and BeginStatement blocks keep such lines as part of their statements.
+func (mp *migrationParser) honoursDelimiter() bool {
+	return (mp.cfg.dialect == MySQL || mp.cfg.dialect == "") && !mp.statementBlock.IsValid()
+}
+
+// afterSQL returns true if SQL has been encountered, which ends the header of the migration.
+func (mp *migrationParser) afterSQL() bool {
+	// Statement blocks are added to the migration without being buffered.
+	return !mp.isFirstLine && (mp.buf.Len() > 0 || mp.statementCount > 0)
+}
+
+// directive applies a directive without arguments.
+func (mp *migrationParser) directive(position Position, name string) error {
+	switch name {
+	case optionNoTransaction:
+		if mp.afterSQL() {
+			return newError(position, "%s%s must be in the first line of the migration", sqlCmdPrefix, optionNoTransaction)
+		}
+		mp.p.UseTransaction = false
+
+	case optionBaseline:
+		if mp.afterSQL() {
+			return newError(position, "%s%s must be in the header of the migration", sqlCmdPrefix, optionBaseline)
+		}
+		mp.p.Baseline = true
+
+	case optionBeginStatement:
+		if mp.statementBlock.IsValid() {
+			return newError(position, "%s%s cannot be nested in the %s%s at %s", sqlCmdPrefix, optionBeginStatement, sqlCmdPrefix, optionBeginStatement, mp.statementBlock)
+		}
+
+		// Add lines encountered before beginning the statement
+		mp.flush()
+		mp.statementBlock = position
+
+	case optionEndStatement:
+		if !mp.statementBlock.IsValid() {
+			return newError(position, "%s%s without a preceding %s%s", sqlCmdPrefix, optionEndStatement, sqlCmdPrefix, optionBeginStatement)
+		}
+
+		// Add the lines encountered during a statement block as 1 block
+		mp.addStatement(string(dropCR(mp.buf.Bytes())), 0)
+		mp.reset()
+		mp.statementBlock = Position{}
+	}
+
+	return nil
+}
+
+// metadata adds a directive in the header of the migration to its metadata.
+func (mp *migrationParser) metadata(position Position, name, value string) error {
+	if _, ok := mp.p.Metadata[name]; ok {
+		return newError(position, "%s%s must only appear once in the header of the migration", sqlCmdPrefix, name)
+	}
+
+	if mp.p.Metadata == nil {
+		mp.p.Metadata = map[string]string{}
+	}
+
+	mp.p.Metadata[name] = value
+
+	return nil
+}
+
+// statementDirective sets the options of the next statement, or of the next statement block.
+func (mp *migrationParser) statementDirective(position Position, name, argument string) error {
+	if mp.statementBlock.IsValid() {
+		return newError(position, "%s%s must precede the %s%s at %s", sqlCmdPrefix, name, sqlCmdPrefix, optionBeginStatement, mp.statementBlock)
+	}
+
+	if argument == "" {
+		return newError(position, "%s%s requires an argument", sqlCmdPrefix, name)
+	}
 
-		if !p.UseTransaction {
-			p.Statements = append(p.Statements, splitStatementsBySemicolon(withoutCR)...)
+	if !mp.optionsPosition.IsValid() {
+		// The next statement must be added on its own, so that the options only apply to it
+		mp.flush()
+		mp.optionsPosition = position
+	}
+
+	switch name {
+	case optionIgnoreError:
+		if mp.options.IgnoreError != nil {
+			return newError(position, "%s%s is already set for the next statement", sqlCmdPrefix, name)
+		}
+
+		pattern, err := regexp.Compile(argument)
+		if err != nil {
+			return newError(position, "invalid regular expression in %s%s: %s", sqlCmdPrefix, name, err)
+		}
+
+		mp.options.IgnoreError = pattern
+
+	case optionRetry:
+		if mp.options.Retries > 0 {
+			return newError(position, "%s%s is already set for the next statement", sqlCmdPrefix, name)
+		}
+
+		fields := strings.Fields(argument)
+
+		retries, err := strconv.Atoi(fields[0])
+		if err != nil || retries < 1 || len(fields) > 2 {
+			return newError(position, "%s%s requires the number of retries, optionally followed by the delay between them, such as 3 or 3 5s", sqlCmdPrefix, name)
+		}
+
+		mp.options.Retries = retries
+
+		if len(fields) == 2 {
+			delay, err := time.ParseDuration(fields[1])
+			if err != nil || delay < 0 {
+				return newError(position, "invalid delay %q in %s%s", fields[1], sqlCmdPrefix, name)
+			}
+
+			mp.options.RetryDelay = delay
+		}
+
+	case optionAllow:
+		for _, rule := range strings.Split(argument, ",") {
+			rule = strings.TrimSpace(rule)
+			if rule == "" {
+				return newError(position, "%s%s requires a comma-separated list of rule names", sqlCmdPrefix, name)
+			}
+
+			mp.options.Allow = append(mp.options.Allow, rule)
+		}
+	}
+
+	return nil
+}
+
+// optionsDirective returns the name of a directive setting the options of the next statement.
+func (mp *migrationParser) optionsDirective() string {
+	switch {
+	case mp.options.IgnoreError != nil:
+		return optionIgnoreError
+	case mp.options.Retries > 0:
+		return optionRetry
+	default:
+		return optionAllow
+	}
+}
+
+// include reads the file at path in place of the Include directive at position.
+func (mp *migrationParser) include(position Position, path string) error {
+	if mp.cfg.resolve == nil {
+		return newError(position, "cannot include %s: no include resolver was configured", path)
+	}
+
+	files := make([]string, 0, len(mp.sources))
+
+	for _, source := range mp.sources {
+		files = append(files, source.file)
+	}
+
+	for _, file := range files {
+		if file == path {
+			return newError(position, "cannot include %s: include cycle %s -> %s", path, strings.Join(files, " -> "), path)
+		}
+	}
+
+	r, err := mp.cfg.resolve(path)
+	if err != nil {
+		return newError(position, "error including %s: %s", path, err)
+	}
+
+	mp.sources = append(mp.sources, &lineSource{
+		r:    bufio.NewReader(r),
+		file: path,
+	})
+
+	return nil
+}
+
+// flush adds the statements of the buffered lines to the migration. Migrations run within a transaction
+// execute the lines as a single statement, unless they are streamed or the delimiter has been changed.
+func (mp *migrationParser) flush() {
+	withoutCR := string(dropCR(mp.buf.Bytes()))
+
+	if strings.TrimSpace(withoutCR) != "" {
+		if mp.p.UseTransaction && !mp.stream && mp.delimiter == defaultDelimiter {
+			mp.addStatement(withoutCR, 0)
 		} else {
-			p.Statements = append(p.Statements, withoutCR)
+			offset := 0
+
+			for _, statement := range splitStatements(withoutCR, mp.cfg.dialect, mp.delimiter) {
+				mp.addDelimitedStatement(statement, offset)
+				offset += len(statement)
+			}
 		}
 	}
 
-	return p, nil
+	mp.reset()
 }
 
-func scanLines(data []byte, atEOF bool) (advance int, token []byte, err error) {
-	if atEOF && len(data) == 0 {
-		return 0, nil, nil
+// addCompleteStatements adds the statements at the start of the buffer that are terminated by the delimiter,
+// and removes them from the buffer. Unless the migration is streamed, only the statement with options is added.
+func (mp *migrationParser) addCompleteStatements() {
+	for mp.stream || mp.optionsPosition.IsValid() {
+		end := mp.splitter.split(mp.buf.Bytes(), &mp.rules, mp.delimiter)
+		if end < 0 {
+			return
+		}
+
+		mp.addDelimitedStatement(string(mp.buf.Next(end)), 0)
+
+		// Keep the line containing the start of the remaining buffer
+		i := 0
+
+		for i+1 < len(mp.lines) && mp.lines[i+1].offset <= end {
+			i++
+		}
+
+		mp.lines = mp.lines[i:]
+
+		for j := range mp.lines {
+			mp.lines[j].offset -= end
+
+			if mp.lines[j].offset < 0 {
+				mp.lines[j].offset = 0
+			}
+		}
 	}
-	if i := bytes.IndexByte(data, '\n'); i >= 0 {
-		// We have a full newline-terminated line.
-		return i + 1, data[0 : i+1], nil
+}
+
+// addDelimitedStatement adds a statement ending with the delimiter. Statements ending with a custom
+// delimiter are added without it, so that they can be executed by the driver. Statements containing
+// only comments are skipped, unless they are the tail of a migration split by semicolons.
+func (mp *migrationParser) addDelimitedStatement(statement string, offset int) {
+	if mp.delimiter != defaultDelimiter {
+		offset += len(statement) - len(strings.TrimLeftFunc(statement, unicode.IsSpace))
+		statement = strings.TrimSuffix(strings.TrimSpace(statement), mp.delimiter)
 	}
-	// If we're at EOF, we have a final, non-terminated line. Return it.
-	if atEOF {
-		return len(data), data, nil
+
+	if (mp.stream || mp.delimiter != defaultDelimiter) && isEmptyStatement(statement, mp.cfg.dialect) {
+		return
 	}
-	// Request more data.
-	return 0, nil, nil
+
+	mp.addStatement(statement, offset)
+}
+
+// addStatement adds a statement starting at the offset in the buffer to the migration.
+func (mp *migrationParser) addStatement(statement string, offset int) {
+	offset += len(statement) - len(strings.TrimLeftFunc(statement, unicode.IsSpace))
+
+	var position Position
+
+	for _, line := range mp.lines {
+		if line.offset > offset {
+			break
+		}
+		position = line.position
+	}
+
+	if mp.optionsPosition.IsValid() {
+		for len(mp.p.Options) < len(mp.p.Statements) {
+			mp.p.Options = append(mp.p.Options, StatementOptions{})
+		}
+
+		mp.p.Options = append(mp.p.Options, mp.options)
+		mp.options = StatementOptions{}
+		mp.optionsPosition = Position{}
+	}
+
+	mp.p.Statements = append(mp.p.Statements, statement)
+	mp.p.Positions = append(mp.p.Positions, position)
+	mp.statementCount++
+}
+
+func (mp *migrationParser) reset() {
+	mp.buf.Reset()
+	mp.lines = mp.lines[:0]
+	mp.splitter = splitter{}
 }
 
 func dropCR(data []byte) []byte {
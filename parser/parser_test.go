@@ -2,9 +2,14 @@ package parser
 
 import (
 	"bytes"
+	"errors"
+	"fmt"
+	"io"
 	"reflect"
+	"regexp"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestParser(t *testing.T) {
@@ -152,3 +157,679 @@ func TestNoTransactionMustBeInFirstLine(t *testing.T) {
 		t.Error("Expected parser to return error if -- +migration noTransaction was not the first line, but got no error")
 	}
 }
+
+func TestUnresolvedTODO(t *testing.T) {
+	testMigration := `DROP TABLE test_table1;
+
+	-- +migration TODO: cannot infer the inverse of the following statement
+	-- UPDATE test_table2 SET id = 1;
+	`
+
+	_, err := Parse(strings.NewReader(testMigration))
+	if err == nil {
+		t.Error("Expected parser to return error if the migration contains a TODO, but got no error")
+	}
+}
+
+func TestSplitStatements(t *testing.T) {
+	statements := SplitStatements(`
+		CREATE TABLE test_table1 (id integer not null primary key);
+
+		CREATE TABLE test_table2 (id integer not null primary key);
+	`)
+
+	expected := []string{
+		"CREATE TABLE test_table1 (id integer not null primary key);",
+		"CREATE TABLE test_table2 (id integer not null primary key);",
+	}
+
+	if !reflect.DeepEqual(statements, expected) {
+		t.Errorf("Expected statements %q, got %q", expected, statements)
+	}
+}
+
+func TestSplitStatementsWithDialects(t *testing.T) {
+	testCases := []struct {
+		dialect  Dialect
+		sql      string
+		expected []string
+	}{
+		{
+			dialect: "",
+			sql:     `INSERT INTO t (a, "b;c", ` + "`d;e`" + `) VALUES ('it''s; fine'); -- trailing; comment`,
+			expected: []string{
+				`INSERT INTO t (a, "b;c", ` + "`d;e`" + `) VALUES ('it''s; fine');`,
+			},
+		},
+		{
+			dialect: Postgres,
+			sql: `CREATE FUNCTION f() RETURNS trigger AS $body$
+BEGIN
+	RAISE NOTICE 'a;b';
+	RETURN NEW;
+END;
+$body$ LANGUAGE plpgsql;
+/* a /* nested; */ comment; */ SELECT E'\';', $1;`,
+			expected: []string{
+				"CREATE FUNCTION f() RETURNS trigger AS $body$\nBEGIN\n\tRAISE NOTICE 'a;b';\n\tRETURN NEW;\nEND;\n$body$ LANGUAGE plpgsql;",
+				`/* a /* nested; */ comment; */ SELECT E'\';', $1;`,
+			},
+		},
+		{
+			dialect: MySQL,
+			sql:     "INSERT INTO t VALUES ('a\\';b', \"c;d\");\n# comment;\nSELECT 5--1;",
+			expected: []string{
+				"INSERT INTO t VALUES ('a\\';b', \"c;d\");",
+				"# comment;\nSELECT 5--1;",
+			},
+		},
+		{
+			dialect: SQLite,
+			sql:     "CREATE TABLE [a;b] (id integer);\nSELECT 1;",
+			expected: []string{
+				"CREATE TABLE [a;b] (id integer);",
+				"SELECT 1;",
+			},
+		},
+	}
+
+	for i, testCase := range testCases {
+		statements := SplitStatements(testCase.sql, WithDialect(testCase.dialect))
+
+		if !reflect.DeepEqual(statements, testCase.expected) {
+			t.Errorf("Expected statements %q for test case %d, got %q", testCase.expected, i, statements)
+		}
+	}
+}
+
+func TestStatementHelpers(t *testing.T) {
+	statement := StripComments("\n-- +migration Description: creates f\n-- creates f\nCREATE OR REPLACE FUNCTION f() RETURNS integer AS $$ SELECT 1; $$ LANGUAGE sql;\n")
+
+	if !strings.HasPrefix(statement, "CREATE OR REPLACE FUNCTION") || strings.HasSuffix(statement, "\n") {
+		t.Errorf("Expected comments and whitespace to be stripped, got %q", statement)
+	}
+
+	if !IsProcedural(statement) {
+		t.Errorf("Expected %q to be procedural", statement)
+	}
+
+	if IsProcedural("CREATE TABLE functions (id integer);") {
+		t.Error("Expected a CREATE TABLE statement not to be procedural")
+	}
+
+	if statement := StripComments("-- only a comment"); statement != "" {
+		t.Errorf("Expected a statement of comments to be empty, got %q", statement)
+	}
+
+	if !regexp.MustCompile(`^` + Name + `$`).MatchString(`"public".[my table]`) {
+		t.Error("Expected Name to match a quoted, qualified name")
+	}
+}
+
+func TestSplitterResumesTokens(t *testing.T) {
+	testCases := []struct {
+		dialect Dialect
+		lines   []string
+	}{
+		{Postgres, []string{"CREATE FUNCTION f() RETURNS trigger AS $body$\n", "BEGIN\n", "\tRAISE NOTICE 'a;b';\n", "END;\n", "$body$ LANGUAGE plpgsql;\n", "SELECT 1;\n"}},
+		{Postgres, []string{"/* a /* nested;\n", "*/ comment; */ SELECT E'it\\'s\n", "a;b', 'c;\n", "d';\n", "SELECT 2;\n"}},
+		{MySQL, []string{"INSERT INTO t VALUES ('a\\'\n", ";b', \"c;\n", "d\");\n", "SELECT 3;\n"}},
+	}
+
+	for i, testCase := range testCases {
+		rules := rulesFor(testCase.dialect)
+
+		var (
+			s          splitter
+			buf        []byte
+			statements []string
+		)
+
+		for _, line := range testCase.lines {
+			buf = append(buf, line...)
+
+			for {
+				end := s.split(buf, &rules, defaultDelimiter)
+				if end < 0 {
+					break
+				}
+
+				statements = append(statements, string(buf[:end]))
+				buf = buf[end:]
+			}
+
+			// Open tokens are not scanned again from their start.
+			if s.open != nil && s.pos < len(buf)-len(line) {
+				t.Errorf("Expected test case %d to resume the open token in the last line, resumes at %d of %d", i, s.pos, len(buf))
+			}
+		}
+
+		expected := splitStatements(strings.Join(testCase.lines, ""), testCase.dialect, defaultDelimiter)
+		expected[len(expected)-1] = strings.TrimSuffix(expected[len(expected)-1], "\n")
+
+		if !reflect.DeepEqual(statements, expected) {
+			t.Errorf("Expected statements %q for test case %d, got %q", expected, i, statements)
+		}
+	}
+}
+
+func TestParseNoTransactionWithDollarQuotes(t *testing.T) {
+	testMigration := `-- +migration NoTransaction
+CREATE FUNCTION f() RETURNS integer AS $$ SELECT 1; $$ LANGUAGE sql;
+CREATE INDEX CONCURRENTLY idx ON t (id);
+`
+
+	parsed, err := Parse(strings.NewReader(testMigration), WithDialect(Postgres))
+	if err != nil {
+		t.Fatalf("Unexpected error while parsing migration: %s", err)
+	}
+
+	expected := []string{
+		"CREATE FUNCTION f() RETURNS integer AS $$ SELECT 1; $$ LANGUAGE sql;",
+		"\nCREATE INDEX CONCURRENTLY idx ON t (id);\n",
+	}
+
+	if !reflect.DeepEqual(parsed.Statements, expected) {
+		t.Errorf("Expected statements %q, got %q", expected, parsed.Statements)
+	}
+}
+
+func TestParseDelimiter(t *testing.T) {
+	testMigration := "CREATE TABLE test_table1 (id integer not null primary key);\n" +
+		"DELIMITER ;;\n" +
+		"/*!50003 CREATE*/ /*!50003 TRIGGER `test_trigger` BEFORE UPDATE ON `test_table1` FOR EACH ROW BEGIN\n" +
+		"    SET NEW.id = OLD.id;\n" +
+		"END */;;\n" +
+		"CREATE PROCEDURE test_procedure()\n" +
+		"BEGIN\n" +
+		"    SELECT ';;';\n" +
+		"END;;\n" +
+		"DELIMITER ;\n" +
+		"CREATE TABLE test_table2 (id integer not null primary key);\n"
+
+	parsed, err := Parse(strings.NewReader(testMigration), WithDialect(MySQL))
+	if err != nil {
+		t.Fatalf("Unexpected error while parsing migration: %s", err)
+	}
+
+	expected := []string{
+		"CREATE TABLE test_table1 (id integer not null primary key);\n",
+		"/*!50003 CREATE*/ /*!50003 TRIGGER `test_trigger` BEFORE UPDATE ON `test_table1` FOR EACH ROW BEGIN\n    SET NEW.id = OLD.id;\nEND */",
+		"CREATE PROCEDURE test_procedure()\nBEGIN\n    SELECT ';;';\nEND",
+		"CREATE TABLE test_table2 (id integer not null primary key);\n",
+	}
+
+	if !reflect.DeepEqual(parsed.Statements, expected) {
+		t.Errorf("Expected statements %q, got %q", expected, parsed.Statements)
+	}
+}
+
+func TestParseDelimiterIsMySQLOnly(t *testing.T) {
+	testMigration := "-- +migration NoTransaction\n" +
+		"INSERT INTO notes (body) VALUES ('\n" +
+		"DELIMITER $$\n" +
+		"');\n" +
+		"CREATE TABLE test_table1 (id integer not null primary key);\n"
+
+	parsed, err := Parse(strings.NewReader(testMigration), WithDialect(Postgres))
+	if err != nil {
+		t.Fatalf("Unexpected error while parsing migration: %s", err)
+	}
+
+	expected := []string{
+		"INSERT INTO notes (body) VALUES ('\nDELIMITER $$\n');",
+		"\nCREATE TABLE test_table1 (id integer not null primary key);\n",
+	}
+
+	if !reflect.DeepEqual(parsed.Statements, expected) {
+		t.Errorf("Expected DELIMITER to be ignored for postgres, got statements %q", parsed.Statements)
+	}
+
+	// DELIMITER lines within BeginStatement blocks are part of the statement for MySQL as well
+	testMigration = "-- +migration BeginStatement\n" +
+		"INSERT INTO notes (body) VALUES ('\n" +
+		"DELIMITER ;;\n" +
+		"');\n" +
+		"-- +migration EndStatement\n" +
+		"CREATE TABLE test_table1 (id integer not null primary key);\n"
+
+	parsed, err = Parse(strings.NewReader(testMigration), WithDialect(MySQL))
+	if err != nil {
+		t.Fatalf("Unexpected error while parsing migration: %s", err)
+	}
+
+	if len(parsed.Statements) != 2 || !strings.Contains(parsed.Statements[0], "DELIMITER ;;") {
+		t.Errorf("Expected DELIMITER to be kept within the BeginStatement block, got statements %q", parsed.Statements)
+	}
+}
+
+func TestParseInclude(t *testing.T) {
+	files := map[string]string{
+		"shared/audit_trigger.sql": "CREATE TRIGGER audit AFTER UPDATE ON test_table1 FOR EACH ROW EXECUTE FUNCTION audit();",
+		"shared/cycle_a.sql":       "-- +migration Include: shared/cycle_b.sql",
+		"shared/cycle_b.sql":       "-- +migration Include: shared/cycle_a.sql",
+	}
+
+	resolve := func(path string) (io.Reader, error) {
+		content, ok := files[path]
+		if !ok {
+			return nil, fmt.Errorf("file %s does not exist", path)
+		}
+		return strings.NewReader(content), nil
+	}
+
+	testMigration := `-- +migration NoTransaction
+CREATE TABLE test_table1 (id integer not null primary key);
+-- +migration Include: shared/audit_trigger.sql
+CREATE TABLE test_table2 (id integer not null primary key);
+`
+
+	parsed, err := Parse(strings.NewReader(testMigration), WithIncludeResolver(resolve))
+	if err != nil {
+		t.Fatalf("Unexpected error while parsing migration: %s", err)
+	}
+
+	expected := []string{
+		"CREATE TABLE test_table1 (id integer not null primary key);",
+		"\nCREATE TRIGGER audit AFTER UPDATE ON test_table1 FOR EACH ROW EXECUTE FUNCTION audit();",
+		"\nCREATE TABLE test_table2 (id integer not null primary key);\n",
+	}
+
+	if !reflect.DeepEqual(parsed.Statements, expected) {
+		t.Errorf("Expected statements %q, got %q", expected, parsed.Statements)
+	}
+
+	if _, err := Parse(strings.NewReader("-- +migration Include: shared/cycle_a.sql"), WithIncludeResolver(resolve)); err == nil {
+		t.Error("Expected parser to return error for an include cycle, but got no error")
+	}
+
+	if _, err := Parse(strings.NewReader("-- +migration Include: 1_init.up.sql"), WithFileName("1_init.up.sql"), WithIncludeResolver(resolve)); err == nil {
+		t.Error("Expected parser to return error for a migration including itself, but got no error")
+	}
+
+	if _, err := Parse(strings.NewReader("-- +migration Include: shared/audit_trigger.sql")); err == nil {
+		t.Error("Expected parser to return error for an include without a resolver, but got no error")
+	}
+}
+
+func TestParsePositions(t *testing.T) {
+	resolve := func(path string) (io.Reader, error) {
+		return strings.NewReader("\nCREATE TABLE included (id integer);\n"), nil
+	}
+
+	testMigration := `-- +migration NoTransaction
+
+CREATE TABLE test_table1 (id integer not null primary key);
+CREATE TABLE test_table2 (
+    id integer not null primary key
+);
+-- +migration Include: shared/included.sql
+-- +migration BeginStatement
+CREATE TRIGGER test_trigger BEFORE UPDATE ON test_table1 FOR EACH ROW BEGIN
+    INSERT INTO test_table2 SET id = OLD.id;
+END
+-- +migration EndStatement
+`
+
+	parsed, err := Parse(strings.NewReader(testMigration), WithFileName("1_init.up.sql"), WithIncludeResolver(resolve))
+	if err != nil {
+		t.Fatalf("Unexpected error while parsing migration: %s", err)
+	}
+
+	expected := []Position{
+		{File: "1_init.up.sql", Line: 3},
+		{File: "1_init.up.sql", Line: 4},
+		{File: "shared/included.sql", Line: 2},
+		{File: "1_init.up.sql", Line: 9},
+	}
+
+	if !reflect.DeepEqual(parsed.Positions, expected) {
+		t.Errorf("Expected positions %v, got %v", expected, parsed.Positions)
+	}
+
+	if position := parsed.StatementPosition(3).String(); position != "1_init.up.sql:9" {
+		t.Errorf("Expected position to be formatted as 1_init.up.sql:9, got %s", position)
+	}
+
+	if parsed.StatementPosition(4).IsValid() {
+		t.Error("Expected position of a statement that does not exist to be invalid")
+	}
+}
+
+func TestParseUpDown(t *testing.T) {
+	testMigration := `-- Adds an index to test_table1
+
+-- +migration Up
+-- +migration NoTransaction
+CREATE INDEX CONCURRENTLY idx ON test_table1 (id);
+
+-- +migration Down
+DROP INDEX idx;
+`
+
+	up, down, err := ParseUpDown(strings.NewReader(testMigration), WithFileName("7_add_index.sql"))
+	if err != nil {
+		t.Fatalf("Unexpected error while parsing migration: %s", err)
+	}
+
+	if up.UseTransaction {
+		t.Error("Expected up migration to be run without a transaction")
+	}
+
+	if !reflect.DeepEqual(up.Statements, []string{"CREATE INDEX CONCURRENTLY idx ON test_table1 (id);\n\n"}) {
+		t.Errorf("Unexpected up statements %q", up.Statements)
+	}
+
+	if !down.UseTransaction {
+		t.Error("Expected down migration to be run within a transaction")
+	}
+
+	if !reflect.DeepEqual(down.Statements, []string{"DROP INDEX idx;\n"}) {
+		t.Errorf("Unexpected down statements %q", down.Statements)
+	}
+
+	if position := down.StatementPosition(0).String(); position != "7_add_index.sql:8" {
+		t.Errorf("Expected down statement to be at 7_add_index.sql:8, got %s", position)
+	}
+
+	invalidMigrations := []string{
+		"CREATE TABLE test_table1 (id integer);\n-- +migration Up\n",
+		"-- +migration Down\nDROP TABLE test_table1;\n",
+		"-- +migration Up\n-- +migration Down\n-- +migration Up\n",
+	}
+
+	for i, invalidMigration := range invalidMigrations {
+		if _, _, err := ParseUpDown(strings.NewReader(invalidMigration)); err == nil {
+			t.Errorf("Expected parser to return error for invalid migration %d, but got no error", i)
+		}
+	}
+}
+
+func TestParseUpDownHeader(t *testing.T) {
+	up, down, err := ParseUpDown(strings.NewReader("-- Adds an index\n\n-- +migration Up\nCREATE INDEX idx ON test_table1 (id);\n-- +migration Down\nDROP INDEX idx;\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error while parsing migration with comments in the header: %s", err)
+	}
+
+	if !up.UseTransaction || !down.UseTransaction {
+		t.Error("Expected comments in the header not to change the transaction mode of the sections")
+	}
+
+	invalidHeaders := map[string]string{
+		"NoTransaction":        "-- +migration NoTransaction\n-- +migration Up\nCREATE INDEX idx ON test_table1 (id);\n",
+		"Baseline":             "-- +migration Baseline\n-- +migration Up\nCREATE INDEX idx ON test_table1 (id);\n",
+		"misspelled directive": "-- Adds an index\n-- +migration NoTransation\n-- +migration Up\nCREATE INDEX idx ON test_table1 (id);\n",
+		"SQL":                  "CREATE TABLE test_table1 (id integer);\n-- +migration Up\nCREATE INDEX idx ON test_table1 (id);\n",
+	}
+
+	for name, invalidHeader := range invalidHeaders {
+		_, _, err := ParseUpDown(strings.NewReader(invalidHeader), WithFileName("7_add_index.sql"))
+
+		var parseErr *Error
+
+		if !errors.As(err, &parseErr) || parseErr.Position.File != "7_add_index.sql" || !parseErr.Position.IsValid() {
+			t.Errorf("Expected positioned error for %s in the header, got %v", name, err)
+		}
+	}
+
+	_, _, err = ParseUpDown(strings.NewReader("-- Adds an index\n-- +migration NoTransation\n-- +migration Up\nSELECT 1;\n"), WithFileName("7_add_index.sql"))
+	if err == nil || err.Error() != "7_add_index.sql:2: unknown directive -- +migration NoTransation" {
+		t.Errorf("Unexpected error for a misspelled directive in the header: %v", err)
+	}
+}
+
+func TestParseStrictDirectives(t *testing.T) {
+	invalidMigrations := map[string]string{
+		"unknown directive":             "CREATE TABLE test_table1 (id integer);\n-- +migration NoTransation\n",
+		"directive with an argument":    "-- +migration NoTransaction please\nCREATE TABLE test_table1 (id integer);\n",
+		"nested BeginStatement":         "-- +migration BeginStatement\nSELECT 1;\n-- +migration BeginStatement\nSELECT 2;\n-- +migration EndStatement\n",
+		"unmatched EndStatement":        "SELECT 1;\n-- +migration EndStatement\n",
+		"unterminated BeginStatement":   "-- +migration BeginStatement\nSELECT 1;\n",
+		"NoTransaction after statement": "-- +migration BeginStatement\nSELECT 1;\n-- +migration EndStatement\n-- +migration NoTransaction\n",
+	}
+
+	for name, invalidMigration := range invalidMigrations {
+		_, err := Parse(strings.NewReader(invalidMigration), WithFileName("1_init.up.sql"))
+		if err == nil {
+			t.Errorf("Expected parser to return error for %s, but got no error", name)
+			continue
+		}
+
+		var parseErr *Error
+
+		if !errors.As(err, &parseErr) || !parseErr.Position.IsValid() {
+			t.Errorf("Expected positioned error for %s, got %q", name, err)
+		}
+	}
+
+	_, err := Parse(strings.NewReader("CREATE TABLE test_table1 (id integer);\n-- +migration NoTransation\n"), WithFileName("1_init.up.sql"))
+	if err == nil || err.Error() != "1_init.up.sql:2: unknown directive -- +migration NoTransation" {
+		t.Errorf("Unexpected error for an unknown directive: %v", err)
+	}
+
+	parsed, err := Parse(strings.NewReader("-- +migration Owner: team-a\nCREATE TABLE test_table1 (id integer);\n"), WithDirectives("Owner"))
+	if err != nil {
+		t.Fatalf("Unexpected error while parsing migration with a custom directive: %s", err)
+	}
+
+	if len(parsed.Statements) != 1 {
+		t.Errorf("Expected custom directive to be ignored, got statements %q", parsed.Statements)
+	}
+}
+
+func TestParseMetadata(t *testing.T) {
+	testMigration := `-- +migration Description: Add the users table
+-- +migration Ticket: OPS-1234
+-- +migration Reviewed
+CREATE TABLE users (id integer not null primary key);
+-- +migration Ticket: OPS-5678
+`
+
+	parsed, err := Parse(strings.NewReader(testMigration), WithDirectives("Ticket", "Reviewed"))
+	if err != nil {
+		t.Fatalf("Unexpected error while parsing migration: %s", err)
+	}
+
+	expected := map[string]string{
+		"Description": "Add the users table",
+		"Ticket":      "OPS-1234",
+		"Reviewed":    "",
+	}
+
+	if !reflect.DeepEqual(parsed.Metadata, expected) {
+		t.Errorf("Expected metadata %v, got %v", expected, parsed.Metadata)
+	}
+
+	up, down, err := ParseUpDown(strings.NewReader("-- +migration Description: Add an index\n-- +migration Up\nCREATE INDEX idx ON users (id);\n-- +migration Down\n-- +migration Description: Drop the index\nDROP INDEX idx;\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error while parsing migration with up and down sections: %s", err)
+	}
+
+	if up.Metadata["Description"] != "Add an index" || down.Metadata["Description"] != "Drop the index" {
+		t.Errorf("Expected header metadata to apply to sections without their own, got %v and %v", up.Metadata, down.Metadata)
+	}
+
+	invalidMigrations := map[string]string{
+		"Description after SQL":     "CREATE TABLE users (id integer);\n-- +migration Description: Add the users table\n",
+		"Description without value": "-- +migration Description\nCREATE TABLE users (id integer);\n",
+		"repeated Description":      "-- +migration Description: Add the users table\n-- +migration Description: Add users\nCREATE TABLE users (id integer);\n",
+	}
+
+	for name, invalidMigration := range invalidMigrations {
+		_, err := Parse(strings.NewReader(invalidMigration), WithFileName("1_init.up.sql"))
+
+		var parseErr *Error
+
+		if !errors.As(err, &parseErr) || !parseErr.Position.IsValid() {
+			t.Errorf("Expected positioned error for %s, got %v", name, err)
+		}
+	}
+
+	if _, _, err := ParseUpDown(strings.NewReader("-- +migration NoTransaction\n-- +migration Up\nCREATE INDEX idx ON users (id);\n")); err == nil {
+		t.Error("Expected error for NoTransaction outside of a section, but got no error")
+	}
+}
+
+func TestParseStatementOptions(t *testing.T) {
+	testMigration := `CREATE TABLE test_table1 (id integer not null primary key);
+CREATE INDEX test_index ON test_table1 (id);
+-- +migration IgnoreError: does not exist
+DROP INDEX legacy_index;
+CREATE TABLE test_table2 (id integer not null primary key);
+-- +migration Retry: 3 2s
+-- +migration IgnoreError: (?i)timeout
+-- +migration BeginStatement
+CREATE FUNCTION f() RETURNS integer AS $$ SELECT 1; $$ LANGUAGE sql;
+-- +migration EndStatement
+`
+
+	parsed, err := Parse(strings.NewReader(testMigration), WithFileName("1_init.up.sql"))
+	if err != nil {
+		t.Fatalf("Unexpected error while parsing migration: %s", err)
+	}
+
+	expected := []string{
+		"CREATE TABLE test_table1 (id integer not null primary key);\nCREATE INDEX test_index ON test_table1 (id);\n",
+		"DROP INDEX legacy_index;",
+		"\nCREATE TABLE test_table2 (id integer not null primary key);\n",
+		"CREATE FUNCTION f() RETURNS integer AS $$ SELECT 1; $$ LANGUAGE sql;\n",
+	}
+
+	if !reflect.DeepEqual(parsed.Statements, expected) {
+		t.Fatalf("Expected statements %q, got %q", expected, parsed.Statements)
+	}
+
+	if options := parsed.StatementOptions(0); !options.IsZero() {
+		t.Errorf("Expected statement without directives to have no options, got %+v", options)
+	}
+
+	if options := parsed.StatementOptions(1); options.IgnoreError == nil || options.IgnoreError.String() != "does not exist" || options.Retries != 0 {
+		t.Errorf("Unexpected options for statement with IgnoreError: %+v", options)
+	}
+
+	if options := parsed.StatementOptions(2); !options.IsZero() {
+		t.Errorf("Expected statement after the statement with options to have no options, got %+v", options)
+	}
+
+	if options := parsed.StatementOptions(3); options.IgnoreError == nil || options.Retries != 3 || options.RetryDelay != 2*time.Second {
+		t.Errorf("Unexpected options for statement block with Retry and IgnoreError: %+v", options)
+	}
+
+	if position := parsed.StatementPosition(1).String(); position != "1_init.up.sql:4" {
+		t.Errorf("Expected statement with options at 1_init.up.sql:4, got %s", position)
+	}
+
+	parsed, err = Parse(strings.NewReader("-- +migration Allow: drop-table, drop-column\nDROP TABLE legacy_table;\n"))
+	if err != nil {
+		t.Fatalf("Unexpected error while parsing migration with an Allow directive: %s", err)
+	}
+
+	if options := parsed.StatementOptions(0); !reflect.DeepEqual(options.Allow, []string{"drop-table", "drop-column"}) || options.HandlesErrors() {
+		t.Errorf("Unexpected options for statement with Allow: %+v", options)
+	}
+
+	invalidMigrations := map[string]string{
+		"IgnoreError without pattern":          "-- +migration IgnoreError\nDROP INDEX legacy_index;\n",
+		"invalid IgnoreError pattern":          "-- +migration IgnoreError: (\nDROP INDEX legacy_index;\n",
+		"invalid Retry count":                  "-- +migration Retry: many\nDROP INDEX legacy_index;\n",
+		"invalid Retry delay":                  "-- +migration Retry: 3 soon\nDROP INDEX legacy_index;\n",
+		"repeated Retry":                       "-- +migration Retry: 3\n-- +migration Retry: 2\nDROP INDEX legacy_index;\n",
+		"Retry without statement":              "DROP INDEX legacy_index;\n-- +migration Retry: 3\n",
+		"IgnoreError within a statement block": "-- +migration BeginStatement\n-- +migration IgnoreError: exists\nSELECT 1;\n-- +migration EndStatement\n",
+		"empty rule in Allow":                  "-- +migration Allow: drop-table,\nDROP TABLE legacy_table;\n",
+	}
+
+	for name, invalidMigration := range invalidMigrations {
+		_, err := Parse(strings.NewReader(invalidMigration), WithFileName("1_init.up.sql"))
+
+		var parseErr *Error
+
+		if !errors.As(err, &parseErr) || !parseErr.Position.IsValid() {
+			t.Errorf("Expected positioned error for %s, got %v", name, err)
+		}
+	}
+}
+
+func TestStatementReader(t *testing.T) {
+	testMigration := `-- +migration Baseline
+
+CREATE TABLE test_table1 (id integer not null primary key);
+INSERT INTO test_table1 (id) VALUES (1), (2),
+    (3); INSERT INTO test_table1 (id) VALUES (4);
+CREATE FUNCTION f() RETURNS integer AS $$ SELECT 1; $$ LANGUAGE sql;
+-- +migration BeginStatement
+CREATE TRIGGER test_trigger BEFORE UPDATE ON test_table1 FOR EACH ROW BEGIN
+    INSERT INTO test_table2 SET id = OLD.id;
+END
+-- +migration EndStatement
+-- +migration Retry: 2
+INSERT INTO test_table1 (id) VALUES ('` + strings.Repeat("a", 100000) + `');
+-- trailing comment
+`
+
+	sr, err := NewStatementReader(strings.NewReader(testMigration), WithFileName("1_init.up.sql"))
+	if err != nil {
+		t.Fatalf("Unexpected error while creating statement reader: %s", err)
+	}
+
+	if !sr.Baseline() || !sr.UseTransaction() {
+		t.Errorf("Expected header to be read, got baseline %t and transaction %t", sr.Baseline(), sr.UseTransaction())
+	}
+
+	var (
+		statements []string
+		lines      []int
+		retried    []int
+	)
+
+	for {
+		statement, position, err := sr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("Unexpected error while reading statements: %s", err)
+		}
+
+		statements = append(statements, strings.TrimSpace(statement))
+		lines = append(lines, position.Line)
+
+		if !sr.Options().IsZero() {
+			retried = append(retried, len(statements)-1)
+		}
+	}
+
+	expected := []string{
+		"CREATE TABLE test_table1 (id integer not null primary key);",
+		"INSERT INTO test_table1 (id) VALUES (1), (2),\n    (3);",
+		"INSERT INTO test_table1 (id) VALUES (4);",
+		"CREATE FUNCTION f() RETURNS integer AS $$ SELECT 1; $$ LANGUAGE sql;",
+		"CREATE TRIGGER test_trigger BEFORE UPDATE ON test_table1 FOR EACH ROW BEGIN\n    INSERT INTO test_table2 SET id = OLD.id;\nEND",
+		"INSERT INTO test_table1 (id) VALUES ('" + strings.Repeat("a", 100000) + "');",
+	}
+
+	if !reflect.DeepEqual(statements, expected) {
+		t.Errorf("Expected statements %q, got %q", expected, statements)
+	}
+
+	if expectedLines := []int{3, 4, 5, 6, 8, 13}; !reflect.DeepEqual(lines, expectedLines) {
+		t.Errorf("Expected statements on lines %v, got %v", expectedLines, lines)
+	}
+
+	if !reflect.DeepEqual(retried, []int{5}) {
+		t.Errorf("Expected only the last statement to have options, got statements %v", retried)
+	}
+}
+
+func TestParseLongLines(t *testing.T) {
+	statement := "INSERT INTO test_table1 (id) VALUES " + strings.Repeat("(1), ", 100000) + "(1);"
+
+	parsed, err := Parse(strings.NewReader(statement))
+	if err != nil {
+		t.Fatalf("Unexpected error while parsing migration: %s", err)
+	}
+
+	if !reflect.DeepEqual(parsed.Statements, []string{statement}) {
+		t.Error("Expected lines longer than the default token limit of bufio.Scanner to be parsed")
+	}
+}
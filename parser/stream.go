@@ -0,0 +1,89 @@
+package parser
+
+import "io"
+
+// StatementReader reads the statements of a migration one at a time, without reading the whole migration
+// into memory. Unlike Parse, it splits migrations run within a transaction into their statements, so that
+// drivers can execute them as they are read.
+type StatementReader struct {
+	mp      *migrationParser
+	done    bool
+	options StatementOptions
+}
+
+// NewStatementReader creates a StatementReader for the migration read from r. The header of the migration
+// is read, so that UseTransaction and Baseline can be called before the statements are read.
+func NewStatementReader(r io.Reader, opts ...Option) (*StatementReader, error) {
+	sr := &StatementReader{
+		mp: newMigrationParser(r, opts, true),
+	}
+
+	for !sr.done && !sr.mp.afterSQL() {
+		if err := sr.read(); err != nil {
+			return nil, err
+		}
+	}
+
+	return sr, nil
+}
+
+// UseTransaction returns false if the migration is marked with NoTransaction.
+func (sr *StatementReader) UseTransaction() bool {
+	return sr.mp.p.UseTransaction
+}
+
+// Baseline returns true if the migration is marked with Baseline.
+func (sr *StatementReader) Baseline() bool {
+	return sr.mp.p.Baseline
+}
+
+// Metadata returns the metadata set by the directives in the header of the migration.
+func (sr *StatementReader) Metadata() map[string]string {
+	return sr.mp.p.Metadata
+}
+
+// Next returns the next statement of the migration and its position. It returns io.EOF after
+// the last statement.
+func (sr *StatementReader) Next() (string, Position, error) {
+	for len(sr.mp.p.Statements) == 0 {
+		if sr.done {
+			return "", Position{}, io.EOF
+		}
+
+		if err := sr.read(); err != nil {
+			return "", Position{}, err
+		}
+	}
+
+	statement, position := sr.mp.p.Statements[0], sr.mp.p.Positions[0]
+	sr.options = sr.mp.p.StatementOptions(0)
+
+	sr.mp.p.Statements = sr.mp.p.Statements[1:]
+	sr.mp.p.Positions = sr.mp.p.Positions[1:]
+
+	if len(sr.mp.p.Options) > 0 {
+		sr.mp.p.Options = sr.mp.p.Options[1:]
+	}
+
+	return statement, position, nil
+}
+
+// Options returns the options of the statement returned by the last call to Next.
+func (sr *StatementReader) Options() StatementOptions {
+	return sr.options
+}
+
+// read parses the next line of the migration.
+func (sr *StatementReader) read() error {
+	more, err := sr.mp.next()
+	if err != nil {
+		return err
+	}
+
+	if !more {
+		sr.done = true
+		return sr.mp.finish()
+	}
+
+	return nil
+}
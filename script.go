@@ -0,0 +1,235 @@
+package migration
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+
+	"github.com/Boostport/migration/parser"
+)
+
+// Dialect identifies the SQL dialect of a database.
+type Dialect = parser.Dialect
+
+// Constants for dialects
+const (
+	Postgres = parser.Postgres
+	MySQL    = parser.MySQL
+	SQLite   = parser.SQLite
+	Phoenix  = parser.Phoenix
+)
+
+const scriptTableName = "schema_migration"
+
+// scriptDialect describes how the driver of a dialect applies migrations.
+type scriptDialect struct {
+	createVersionTable string
+	insertVersion      string
+	deleteVersion      string
+
+	// Empty if the driver does not run migrations within transactions.
+	begin  string
+	commit string
+
+	// Phoenix executes each statement of a migration separately.
+	splitStatements bool
+
+	// MySQL clients split statements on semicolons, so compound statements need a different delimiter.
+	delimitCompoundStatements bool
+}
+
+var scriptDialects = map[Dialect]scriptDialect{
+	Postgres: {
+		createVersionTable: "CREATE TABLE IF NOT EXISTS " + scriptTableName + " (version varchar(255) not null primary key);",
+		insertVersion:      "INSERT INTO " + scriptTableName + " (version) VALUES (%s);",
+		deleteVersion:      "DELETE FROM " + scriptTableName + " WHERE version=%s;",
+		begin:              "BEGIN;",
+		commit:             "COMMIT;",
+	},
+	MySQL: {
+		createVersionTable:        "CREATE TABLE IF NOT EXISTS " + scriptTableName + " (version varchar(255) not null primary key);",
+		insertVersion:             "INSERT INTO " + scriptTableName + " (version) VALUES (%s);",
+		deleteVersion:             "DELETE FROM " + scriptTableName + " WHERE version=%s;",
+		delimitCompoundStatements: true,
+	},
+	// Unlike the sqlite driver, the script honours NoTransaction. See WriteScript.
+	SQLite: {
+		createVersionTable: "CREATE TABLE IF NOT EXISTS " + scriptTableName + " (version varchar(255) not null primary key);",
+		insertVersion:      "INSERT INTO " + scriptTableName + " (version) VALUES (%s);",
+		deleteVersion:      "DELETE FROM " + scriptTableName + " WHERE version=%s;",
+		begin:              "BEGIN TRANSACTION;",
+		commit:             "COMMIT;",
+	},
+	Phoenix: {
+		createVersionTable: "CREATE TABLE IF NOT EXISTS " + scriptTableName + " (version varchar not null primary key) TRANSACTIONAL=true;",
+		insertVersion:      "UPSERT INTO " + scriptTableName + " (version) VALUES (%s);",
+		deleteVersion:      "DELETE FROM " + scriptTableName + " WHERE version=%s;",
+		splitStatements:    true,
+	},
+}
+
+var compoundStatementRegex = regexp.MustCompile(`(?is)\bBEGIN\b.*;.*\bEND\b`)
+
+// WriteScript writes a SQL script to w containing the migrations that would be applied to a database of the
+// given dialect which has already applied the given versions. The direction and max parameters have the same
+// meaning as for Migrate. Like the drivers, the script wraps migrations in transactions unless they are marked
+// with NoTransaction or the dialect does not support transactional migrations, and it updates the
+// schema_migration table after each migration. Go migrations and statements with IgnoreError or Retry directives
+// cannot be written to a script. Options such as WithVariables are applied to the migrations as they would be by
+// Migrate.
+//
+// For SQLite, the script follows the NoTransaction directives of the migrations, while the sqlite driver ignores
+// them and either runs all migrations within transactions or none, depending on the useTransactions parameter of
+// sqlite.New. The script therefore matches a driver created with useTransactions set to true only if no migration is
+// marked with NoTransaction.
+func WriteScript(w io.Writer, migrations Source, applied []string, dialect Dialect, direction Direction, max int, opts ...Option) error {
+	d, ok := scriptDialects[dialect]
+	if !ok {
+		return fmt.Errorf("unsupported dialect %q", dialect)
+	}
+
+	cfg := newConfig(opts)
+	cfg.dialect = dialect
+
+	loader, err := listMigrations(migrations, cfg)
+	if err != nil {
+		return err
+	}
+
+	planned, err := loader.plan(applied, direction, max)
+	if err != nil {
+		return err
+	}
+
+	var b strings.Builder
+
+	b.WriteString("-- Migration script for " + string(dialect) + "\n\n")
+	b.WriteString(d.createVersionTable + "\n")
+
+	for _, migration := range planned {
+		statements := migration.Up
+		migrationFunc := migration.UpFunc
+		updateVersion := d.insertVersion
+
+		if migration.Direction == Down {
+			statements = migration.Down
+			migrationFunc = migration.DownFunc
+			updateVersion = d.deleteVersion
+		}
+
+		if migrationFunc != nil {
+			return fmt.Errorf("migration %s (%s) is a Go migration and cannot be written to a script", migration.ID, migration.Direction)
+		}
+
+		if statements == nil {
+			return fmt.Errorf("migration %s does not have a %s migration", migration.ID, migration.Direction)
+		}
+
+		useTransaction := statements.UseTransaction && d.begin != ""
+
+		b.WriteString("\n-- Migration " + migration.ID + " (" + migration.Direction.String() + ")\n")
+
+		if description, ok := statements.Metadata["Description"]; ok {
+			b.WriteString("-- " + description + "\n")
+		}
+
+		if useTransaction {
+			b.WriteString(d.begin + "\n")
+		}
+
+		err = statements.ForEachStatement(func(statement string, position parser.Position, options parser.StatementOptions) error {
+			if options.HandlesErrors() {
+				return fmt.Errorf("migration %s (%s) uses IgnoreError or Retry at %s, which cannot be written to a script", migration.ID, migration.Direction, position)
+			}
+
+			if d.splitStatements {
+				for _, content := range parser.SplitStatements(statement, parser.WithDialect(dialect)) {
+					writeScriptStatement(&b, content, d.delimitCompoundStatements)
+				}
+				return nil
+			}
+
+			writeScriptStatement(&b, statement, d.delimitCompoundStatements)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		b.WriteString(fmt.Sprintf(updateVersion, quoteLiteral(migration.ID)) + "\n")
+
+		if useTransaction {
+			b.WriteString(d.commit + "\n")
+		}
+	}
+
+	_, err = io.WriteString(w, b.String())
+	return err
+}
+
+func writeScriptStatement(b *strings.Builder, statement string, delimitCompoundStatements bool) {
+	statement = strings.TrimSpace(statement)
+
+	if statement == "" {
+		return
+	}
+
+	// A delimiter appended to a line comment ending the statement would be commented out, so it is written
+	// before the comment instead.
+	code, comment := statement, ""
+
+	if i := trailingComment(statement); i >= 0 {
+		code = strings.TrimRight(statement[:i], " \t\r\n")
+		comment = statement[len(code):]
+	}
+
+	if code == "" {
+		b.WriteString(statement + "\n")
+		return
+	}
+
+	if delimitCompoundStatements && compoundStatementRegex.MatchString(statement) {
+		b.WriteString("DELIMITER $$\n" + strings.TrimSuffix(code, ";") + comment)
+
+		if comment != "" {
+			b.WriteString("\n")
+		}
+
+		b.WriteString("$$\nDELIMITER ;\n")
+		return
+	}
+
+	if !strings.HasSuffix(code, ";") {
+		code += ";"
+	}
+
+	b.WriteString(code + comment + "\n")
+}
+
+// trailingComment returns the position of the line comment ending a statement, or -1 if its last line does
+// not end with a comment. Quotes are only tracked within the last line.
+func trailingComment(statement string) int {
+	start := strings.LastIndex(statement, "\n") + 1
+
+	var quote byte
+
+	for i := start; i < len(statement); i++ {
+		switch c := statement[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '\'' || c == '"' || c == '`':
+			quote = c
+		case c == '-' && strings.HasPrefix(statement[i:], "--"):
+			return i
+		}
+	}
+
+	return -1
+}
+
+func quoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
@@ -0,0 +1,170 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"testing"
+)
+
+func TestWriteScript(t *testing.T) {
+	memoryMigration := &MemoryMigrationSource{
+		Files: map[string]string{
+			"1_init.up.sql":          "-- +migration Description: Create test_table1\nCREATE TABLE test_table1 (id integer not null primary key);",
+			"1_init.down.sql":        "DROP TABLE test_table1;",
+			"2_index.up.sql":         "-- +migration NoTransaction\nCREATE INDEX idx_id ON test_table1 (id);\nCREATE TABLE test_table2 (id integer)",
+			"2_index.down.sql":       "-- +migration NoTransaction\nDROP TABLE test_table2;\nDROP INDEX idx_id;",
+			"3_add_trigger.up.sql":   "-- +migration BeginStatement\nCREATE TRIGGER test_trigger BEFORE UPDATE ON test_table1 FOR EACH ROW BEGIN\n  INSERT INTO test_table2 SET id = OLD.id;\nEND\n-- +migration EndStatement",
+			"3_add_trigger.down.sql": "DROP TRIGGER test_trigger;",
+			"4_it's_quoted.up.sql":   "SELECT 1;",
+			"4_it's_quoted.down.sql": "SELECT 2;",
+		},
+	}
+
+	testCases := []struct {
+		dialect   Dialect
+		applied   []string
+		direction Direction
+		max       int
+		script    string
+	}{
+		{
+			dialect:   Postgres,
+			applied:   []string{},
+			direction: Up,
+			max:       2,
+			script: `-- Migration script for postgres
+
+CREATE TABLE IF NOT EXISTS schema_migration (version varchar(255) not null primary key);
+
+-- Migration 1_init (up)
+-- Create test_table1
+BEGIN;
+CREATE TABLE test_table1 (id integer not null primary key);
+INSERT INTO schema_migration (version) VALUES ('1_init');
+COMMIT;
+
+-- Migration 2_index (up)
+CREATE INDEX idx_id ON test_table1 (id);
+CREATE TABLE test_table2 (id integer);
+INSERT INTO schema_migration (version) VALUES ('2_index');
+`,
+		},
+		{
+			dialect:   MySQL,
+			applied:   []string{"1_init", "2_index"},
+			direction: Up,
+			script: `-- Migration script for mysql
+
+CREATE TABLE IF NOT EXISTS schema_migration (version varchar(255) not null primary key);
+
+-- Migration 3_add_trigger (up)
+DELIMITER $$
+CREATE TRIGGER test_trigger BEFORE UPDATE ON test_table1 FOR EACH ROW BEGIN
+  INSERT INTO test_table2 SET id = OLD.id;
+END$$
+DELIMITER ;
+INSERT INTO schema_migration (version) VALUES ('3_add_trigger');
+
+-- Migration 4_it's_quoted (up)
+SELECT 1;
+INSERT INTO schema_migration (version) VALUES ('4_it''s_quoted');
+`,
+		},
+		{
+			dialect:   Phoenix,
+			applied:   []string{"1_init", "2_index"},
+			direction: Down,
+			max:       1,
+			script: `-- Migration script for phoenix
+
+CREATE TABLE IF NOT EXISTS schema_migration (version varchar not null primary key) TRANSACTIONAL=true;
+
+-- Migration 2_index (down)
+DROP TABLE test_table2;
+DROP INDEX idx_id;
+DELETE FROM schema_migration WHERE version='2_index';
+`,
+		},
+		{
+			dialect:   SQLite,
+			applied:   []string{"1_init"},
+			direction: Down,
+			script: `-- Migration script for sqlite
+
+CREATE TABLE IF NOT EXISTS schema_migration (version varchar(255) not null primary key);
+
+-- Migration 1_init (down)
+BEGIN TRANSACTION;
+DROP TABLE test_table1;
+DELETE FROM schema_migration WHERE version='1_init';
+COMMIT;
+`,
+		},
+	}
+
+	for i, testCase := range testCases {
+		var b strings.Builder
+
+		err := WriteScript(&b, memoryMigration, testCase.applied, testCase.dialect, testCase.direction, testCase.max)
+		if err != nil {
+			t.Errorf("Unexpected error while writing script for test case %d: %s", i, err)
+		}
+
+		if b.String() != testCase.script {
+			t.Errorf("Script for test case %d did not match expected result, got:\n%s", i, b.String())
+		}
+	}
+}
+
+func TestWriteScriptWithUnsupportedMigrations(t *testing.T) {
+	var b strings.Builder
+
+	if err := WriteScript(&b, &MemoryMigrationSource{}, nil, Dialect("oracle"), Up, 0); err == nil {
+		t.Error("Expected error while writing script for an unsupported dialect, but there was no error")
+	}
+
+	source := NewGolangMigrationSource()
+
+	source.AddTxMigration("1_init", Up, func(ctx context.Context, tx *sql.Tx) error {
+		return nil
+	})
+
+	if err := WriteScript(&b, source, nil, Postgres, Up, 0); err == nil {
+		t.Error("Expected error while writing script containing Go migrations, but there was no error")
+	}
+}
+
+func TestWriteScriptWithTrailingComments(t *testing.T) {
+	memoryMigration := &MemoryMigrationSource{
+		Files: map[string]string{
+			"1_init.up.sql": "-- +migration BeginStatement\nCREATE TABLE test_table1 (id integer, name text DEFAULT '--') -- no primary key yet\n-- +migration EndStatement\n-- +migration BeginStatement\nCREATE TRIGGER test_trigger BEFORE UPDATE ON test_table1 FOR EACH ROW BEGIN\n  SET NEW.id = OLD.id;\nEND; -- keeps ids\n-- +migration EndStatement",
+		},
+	}
+
+	testCases := []struct {
+		dialect Dialect
+		script  string
+	}{
+		{
+			dialect: Postgres,
+			script:  "CREATE TABLE test_table1 (id integer, name text DEFAULT '--'); -- no primary key yet\nCREATE TRIGGER test_trigger BEFORE UPDATE ON test_table1 FOR EACH ROW BEGIN\n  SET NEW.id = OLD.id;\nEND; -- keeps ids\n",
+		},
+		{
+			dialect: MySQL,
+			script:  "CREATE TABLE test_table1 (id integer, name text DEFAULT '--'); -- no primary key yet\nDELIMITER $$\nCREATE TRIGGER test_trigger BEFORE UPDATE ON test_table1 FOR EACH ROW BEGIN\n  SET NEW.id = OLD.id;\nEND -- keeps ids\n$$\nDELIMITER ;\n",
+		},
+	}
+
+	for _, testCase := range testCases {
+		var b strings.Builder
+
+		if err := WriteScript(&b, memoryMigration, nil, testCase.dialect, Up, 0); err != nil {
+			t.Fatalf("Unexpected error while writing %s script: %s", testCase.dialect, err)
+		}
+
+		if !strings.Contains(b.String(), testCase.script) {
+			t.Errorf("Expected %s script to terminate statements before their trailing comments, got:\n%s", testCase.dialect, b.String())
+		}
+	}
+}
@@ -1,6 +1,8 @@
 package migration
 
 import (
+	"context"
+	"database/sql"
 	"fmt"
 	"io"
 	"strings"
@@ -13,22 +15,33 @@ type Source interface {
 	GetMigrationFile(file string) (io.Reader, error)
 }
 
+// TxMigrationFunc is a Go migration that is executed by a SQL driver within the transaction
+// used to update the migration versions.
+type TxMigrationFunc func(ctx context.Context, tx *sql.Tx) error
+
+// goMigrationSource is implemented by sources providing Go migrations that can be executed by SQL drivers.
+type goMigrationSource interface {
+	goMigration(file string) (TxMigrationFunc, error)
+}
+
 // GolangMigrationSource implements migration.Source
 type GolangMigrationSource struct {
 	sync.Mutex
-	migrations map[string]func() error
+	migrations   map[string]func() error
+	txMigrations map[string]TxMigrationFunc
 }
 
 // NewGolangMigrationSource creates a source for storing Go functions as migrations.
 func NewGolangMigrationSource() *GolangMigrationSource {
 	return &GolangMigrationSource{
-		migrations: map[string]func() error{},
+		migrations:   map[string]func() error{},
+		txMigrations: map[string]TxMigrationFunc{},
 	}
 }
 
 // AddMigration adds a new migration to the source. The file parameter follows the same conventions as you would use
 // for a physical file for other types of migrations, however you should omit the file extension. Example: 1_init.up
-// and 1_init.down
+// and 1_init.down. It replaces any migration previously added for the same file and direction.
 func (s *GolangMigrationSource) AddMigration(file string, direction Direction, migration func() error) {
 	s.Lock()
 	defer s.Unlock()
@@ -39,9 +52,29 @@ func (s *GolangMigrationSource) AddMigration(file string, direction Direction, m
 		file += ".down"
 	}
 
+	delete(s.txMigrations, file+".go")
 	s.migrations[file+".go"] = migration
 }
 
+// AddTxMigration adds a new migration that receives the context of the run and the transaction of the
+// migration. These migrations must be run using a SQL driver, so that changes made by the migration and
+// the update of the migration versions are committed atomically. The file parameter follows the same
+// conventions as AddMigration, and it also replaces any migration previously added for the same file and
+// direction.
+func (s *GolangMigrationSource) AddTxMigration(file string, direction Direction, migration TxMigrationFunc) {
+	s.Lock()
+	defer s.Unlock()
+
+	if direction == Up {
+		file += ".up"
+	} else if direction == Down {
+		file += ".down"
+	}
+
+	delete(s.migrations, file+".go")
+	s.txMigrations[file+".go"] = migration
+}
+
 // GetMigration gets a golang migration
 func (s *GolangMigrationSource) GetMigration(file string) func() error {
 	s.Lock()
@@ -50,6 +83,34 @@ func (s *GolangMigrationSource) GetMigration(file string) func() error {
 	return s.migrations[file+".go"]
 }
 
+// GetTxMigration gets a golang migration that was added using AddTxMigration
+func (s *GolangMigrationSource) GetTxMigration(file string) TxMigrationFunc {
+	s.Lock()
+	defer s.Unlock()
+
+	return s.txMigrations[file+".go"]
+}
+
+// goMigration returns the migration added for the file. Migrations added using AddMigration are wrapped, so
+// that SQL drivers run them like the migrations added using AddTxMigration instead of recording their version
+// without running them.
+func (s *GolangMigrationSource) goMigration(file string) (TxMigrationFunc, error) {
+	file = strings.TrimSuffix(file, ".go")
+
+	if migration := s.GetTxMigration(file); migration != nil {
+		return migration, nil
+	}
+
+	migration := s.GetMigration(file)
+	if migration == nil {
+		return nil, fmt.Errorf("golang migration %s does not exist", file)
+	}
+
+	return func(ctx context.Context, tx *sql.Tx) error {
+		return migration()
+	}, nil
+}
+
 // ListMigrationFiles lists the available migrations in the source
 func (s *GolangMigrationSource) ListMigrationFiles() ([]string, error) {
 	var keys []string
@@ -61,6 +122,10 @@ func (s *GolangMigrationSource) ListMigrationFiles() ([]string, error) {
 		keys = append(keys, key)
 	}
 
+	for key := range s.txMigrations {
+		keys = append(keys, key)
+	}
+
 	return keys, nil
 }
 
@@ -70,7 +135,8 @@ func (s *GolangMigrationSource) GetMigrationFile(file string) (io.Reader, error)
 	defer s.Unlock()
 
 	_, ok := s.migrations[file]
-	if !ok {
+	_, txOk := s.txMigrations[file]
+	if !ok && !txOk {
 		return nil, fmt.Errorf("migration %s does not exist", file)
 	}
 
@@ -103,3 +169,109 @@ func (m MemoryMigrationSource) GetMigrationFile(name string) (io.Reader, error)
 
 	return strings.NewReader(content), nil
 }
+
+// CompositeMigrationSource combines several sources into one, so that migrations from all of them share the
+// same IDs, ordering and migration versions. For example, SQL files in an EmbedMigrationSource can be mixed with Go
+// functions in a GolangMigrationSource and run using a SQL driver.
+type CompositeMigrationSource struct {
+	sources []Source
+
+	mu sync.Mutex
+
+	// files maps the migration files listed by ListMigrationFiles to the source containing them.
+	files map[string]Source
+}
+
+// NewCompositeMigrationSource creates a source combining the given sources.
+func NewCompositeMigrationSource(sources ...Source) *CompositeMigrationSource {
+	return &CompositeMigrationSource{
+		sources: sources,
+	}
+}
+
+// ListMigrationFiles returns the migration files of all sources. Files must not exist in more than one source.
+func (c *CompositeMigrationSource) ListMigrationFiles() ([]string, error) {
+	var files []string
+
+	index := map[string]Source{}
+
+	for _, source := range c.sources {
+		sourceFiles, err := source.ListMigrationFiles()
+		if err != nil {
+			return nil, err
+		}
+
+		for _, file := range sourceFiles {
+			if _, ok := index[file]; ok {
+				return nil, fmt.Errorf("the migration file %s exists in more than one source", file)
+			}
+
+			index[file] = source
+			files = append(files, file)
+		}
+	}
+
+	c.mu.Lock()
+	c.files = index
+	c.mu.Unlock()
+
+	return files, nil
+}
+
+// GetMigrationFile gets a migration file from the source containing it. Other files, such as files included
+// by migrations, are read from the first source containing them.
+func (c *CompositeMigrationSource) GetMigrationFile(file string) (io.Reader, error) {
+	source, err := c.sourceOf(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if source != nil {
+		return source.GetMigrationFile(file)
+	}
+
+	for _, source := range c.sources {
+		if reader, err := source.GetMigrationFile(file); err == nil {
+			return reader, nil
+		}
+	}
+
+	return nil, fmt.Errorf("the file %s does not exist", file)
+}
+
+func (c *CompositeMigrationSource) goMigration(file string) (TxMigrationFunc, error) {
+	source, err := c.sourceOf(file)
+	if err != nil {
+		return nil, err
+	}
+
+	if source == nil {
+		return nil, fmt.Errorf("the migration file %s does not exist", file)
+	}
+
+	if goSource, ok := source.(goMigrationSource); ok {
+		return goSource.goMigration(file)
+	}
+
+	return nil, nil
+}
+
+// sourceOf returns the source containing the migration file, or nil if the file is not a migration file of any
+// source. The sources are listed once, unless ListMigrationFiles was already called.
+func (c *CompositeMigrationSource) sourceOf(file string) (Source, error) {
+	c.mu.Lock()
+	files := c.files
+	c.mu.Unlock()
+
+	if files == nil {
+		if _, err := c.ListMigrationFiles(); err != nil {
+			return nil, err
+		}
+
+		c.mu.Lock()
+		files = c.files
+		c.mu.Unlock()
+	}
+
+	return files[file], nil
+}
@@ -12,35 +12,37 @@ import (
 	"path"
 )
 
-// EmbedMigrationSource uses an embed.FS that is used to embed files natively in Go 1.16+
-type EmbedMigrationSource struct {
-	EmbedFS embed.FS
+// FSMigrationSource reads migration files from any fs.FS, such as a directory opened using os.DirFS, an
+// fstest.MapFS in tests or an embed.FS.
+type FSMigrationSource struct {
+	FS fs.FS
 
-	// The path in the embed FS to use
+	// The path in the FS to use
 	Dir string
-}
-
-// ListMigrationFiles returns a list of embedded migration files
-func (e EmbedMigrationSource) ListMigrationFiles() ([]string, error) {
 
-	var f fs.FS = e.EmbedFS
-
-	if e.Dir != "" {
-
-		var err error
+	// Recursive lists migration files in subdirectories of Dir as well, for example to organise migrations
+	// in a directory per release. The files are identified by their name, which must be unique across
+	// directories.
+	Recursive bool
+}
 
-		f, err = fs.Sub(f, e.Dir)
+// ListMigrationFiles returns a list of migration files in the directory of the FS. In recursive mode, files in
+// subdirectories are listed with their path relative to the directory, such as 2025-q1/1_init.up.sql.
+func (s FSMigrationSource) ListMigrationFiles() ([]string, error) {
 
-		if err != nil {
-			return nil, fmt.Errorf("error opening subdirectory in embed fs: %s", err)
-		}
+	if s.Recursive {
+		return s.migrationPaths()
+	}
 
+	f, err := s.dir()
+	if err != nil {
+		return nil, err
 	}
 
 	files, err := fs.ReadDir(f, ".")
 
 	if err != nil {
-		return nil, fmt.Errorf("error reading directory from embed fs: %w", err)
+		return nil, fmt.Errorf("error reading directory from fs: %w", err)
 	}
 
 	var migrations []string
@@ -57,8 +59,98 @@ func (e EmbedMigrationSource) ListMigrationFiles() ([]string, error) {
 	return migrations, nil
 }
 
+// GetMigrationFile gets a file from the directory of the FS. Migration files in subdirectories are read using
+// the path they were listed with. Other files, such as files included by migrations, are read relative to the
+// directory as well.
+func (s FSMigrationSource) GetMigrationFile(name string) (io.Reader, error) {
+	file, err := fs.ReadFile(s.FS, path.Join(s.Dir, name))
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(file), nil
+}
+
+func (s FSMigrationSource) dir() (fs.FS, error) {
+	if s.Dir == "" {
+		return s.FS, nil
+	}
+
+	f, err := fs.Sub(s.FS, s.Dir)
+	if err != nil {
+		return nil, fmt.Errorf("error opening subdirectory in fs: %s", err)
+	}
+
+	return f, nil
+}
+
+// migrationPaths returns the paths of the migration files in the directory and its subdirectories, relative
+// to the directory. Migrations are identified by their file name, so a name must not be used in more than
+// one directory.
+func (s FSMigrationSource) migrationPaths() ([]string, error) {
+	f, err := s.dir()
+	if err != nil {
+		return nil, err
+	}
+
+	var paths []string
+
+	dirs := map[string]string{}
+
+	err = fs.WalkDir(f, ".", func(p string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return fmt.Errorf("error reading directory from fs: %w", err)
+		}
+
+		if entry.IsDir() {
+			return nil
+		}
+
+		if _, _, _, ok := parseMigrationFileName(entry.Name()); !ok {
+			return nil
+		}
+
+		if existing, ok := dirs[entry.Name()]; ok {
+			return fmt.Errorf("the migration file %s exists in both %s and %s", entry.Name(), existing, path.Dir(p))
+		}
+
+		dirs[entry.Name()] = path.Dir(p)
+		paths = append(paths, p)
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return paths, nil
+}
+
+// EmbedMigrationSource uses an embed.FS that is used to embed files natively in Go 1.16+
+type EmbedMigrationSource struct {
+	EmbedFS embed.FS
+
+	// The path in the embed FS to use
+	Dir string
+
+	// Recursive lists migration files in subdirectories of Dir as well. See FSMigrationSource.
+	Recursive bool
+}
+
+func (e EmbedMigrationSource) source() FSMigrationSource {
+	return FSMigrationSource{
+		FS:        e.EmbedFS,
+		Dir:       e.Dir,
+		Recursive: e.Recursive,
+	}
+}
+
+// ListMigrationFiles returns a list of embedded migration files
+func (e EmbedMigrationSource) ListMigrationFiles() ([]string, error) {
+	return e.source().ListMigrationFiles()
+}
+
 // GetMigrationFile gets an embedded migration file
 func (e EmbedMigrationSource) GetMigrationFile(name string) (io.Reader, error) {
-	file, err := fs.ReadFile(e.EmbedFS, path.Join(e.Dir, name))
-	return bytes.NewReader(file), err
+	return e.source().GetMigrationFile(name)
 }
@@ -4,8 +4,16 @@
 package migration
 
 import (
+	"bytes"
 	"embed"
+	"io"
+	"io/fs"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
 	"testing"
+	"testing/fstest"
 )
 
 //go:embed test-migrations
@@ -30,3 +38,206 @@ func TestEmbedMigrationSource(t *testing.T) {
 		t.Errorf("Applied %d migrations, but driver is showing %d applied.", applied, len(driver.applied))
 	}
 }
+
+func TestFSMigrationSource(t *testing.T) {
+
+	mapFS := fstest.MapFS{
+		"migrations/1_init.up.sql":        {Data: []byte("CREATE TABLE test_table1 (id integer not null primary key);")},
+		"migrations/1_init.down.sql":      {Data: []byte("DROP TABLE test_table1;")},
+		"migrations/shared/functions.sql": {Data: []byte("SELECT 1;")},
+	}
+
+	source := FSMigrationSource{
+		FS:  mapFS,
+		Dir: "migrations",
+	}
+
+	files, err := source.ListMigrationFiles()
+	if err != nil {
+		t.Fatalf("Unexpected error while listing migration files: %s", err)
+	}
+
+	sort.Strings(files)
+
+	expected := []string{"1_init.down.sql", "1_init.up.sql"}
+
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("Expected migration files %v, got %v", expected, files)
+	}
+
+	reader, err := source.GetMigrationFile("1_init.down.sql")
+	if err != nil {
+		t.Fatalf("Unexpected error while getting migration file: %s", err)
+	}
+
+	content, err := io.ReadAll(reader)
+	if err != nil {
+		t.Fatalf("Unexpected error while reading migration file: %s", err)
+	}
+
+	if string(content) != "DROP TABLE test_table1;" {
+		t.Errorf("Unexpected content of migration file: %q", content)
+	}
+
+	if _, err := source.GetMigrationFile("2_missing.up.sql"); err == nil {
+		t.Error("Expected error while getting a missing migration file")
+	}
+
+	driver := getMockDriver()
+
+	applied, err := Migrate(driver, FSMigrationSource{FS: os.DirFS("test-migrations")}, Up, 0)
+	if err != nil {
+		t.Errorf("Unexpected error while performing fs migration: %s", err)
+	}
+	if applied != 3 {
+		t.Errorf("Expected %d migrations to be applied, %d applied.", 3, applied)
+	}
+}
+
+func TestFSMigrationSourceRecursive(t *testing.T) {
+
+	mapFS := fstest.MapFS{
+		"migrations/2025-q1/1_init.up.sql":     {Data: []byte("-- +migration Include: shared/functions.sql\nCREATE TABLE test_table1 (id integer not null primary key);")},
+		"migrations/2025-q1/1_init.down.sql":   {Data: []byte("DROP TABLE test_table1;")},
+		"migrations/2025-q2/2_update.sql":      {Data: []byte("-- +migration Up\nALTER TABLE test_table1 ADD COLUMN name text;\n-- +migration Down\nALTER TABLE test_table1 DROP COLUMN name;")},
+		"migrations/2025-q2/notes.md":          {Data: []byte("Release notes")},
+		"migrations/3_add_index.up.sql":        {Data: []byte("CREATE INDEX test_index ON test_table1 (name);")},
+		"migrations/shared/functions.sql":      {Data: []byte("SELECT 1;")},
+		"migrations/2025-q3/archive/README.md": {Data: []byte("Archive")},
+	}
+
+	source := FSMigrationSource{
+		FS:        mapFS,
+		Dir:       "migrations",
+		Recursive: true,
+	}
+
+	files, err := source.ListMigrationFiles()
+	if err != nil {
+		t.Fatalf("Unexpected error while listing migration files: %s", err)
+	}
+
+	sort.Strings(files)
+
+	expected := []string{"2025-q1/1_init.down.sql", "2025-q1/1_init.up.sql", "2025-q2/2_update.sql", "3_add_index.up.sql"}
+
+	if !reflect.DeepEqual(files, expected) {
+		t.Errorf("Expected migration files %v, got %v", expected, files)
+	}
+
+	reader, err := source.GetMigrationFile("2025-q2/2_update.sql")
+	if err != nil {
+		t.Fatalf("Unexpected error while getting nested migration file: %s", err)
+	}
+
+	if content, _ := io.ReadAll(reader); !bytes.HasPrefix(content, []byte("-- +migration Up")) {
+		t.Errorf("Unexpected content of nested migration file: %q", content)
+	}
+
+	driver := getMockDriver()
+
+	applied, err := Migrate(driver, source, Up, 0)
+	if err != nil {
+		t.Errorf("Unexpected error while performing recursive migration: %s", err)
+	}
+	if applied != 3 {
+		t.Errorf("Expected %d migrations to be applied, %d applied.", 3, applied)
+	}
+	if expected := []string{"1_init", "2_update", "3_add_index"}; !reflect.DeepEqual(driver.applied, expected) {
+		t.Errorf("Expected migrations %v to be applied, got %v", expected, driver.applied)
+	}
+
+	// Files moved to another directory are read from their new path after the next listing
+	mapFS["migrations/2025-q3/2_update.sql"] = mapFS["migrations/2025-q2/2_update.sql"]
+	delete(mapFS, "migrations/2025-q2/2_update.sql")
+
+	if _, err := Migrations(source, Postgres); err != nil {
+		t.Errorf("Unexpected error while loading moved migration files: %s", err)
+	}
+
+	mapFS["migrations/2025-q2/1_init.up.sql"] = &fstest.MapFile{Data: []byte("SELECT 1;")}
+
+	_, err = source.ListMigrationFiles()
+	if err == nil || !strings.Contains(err.Error(), "exists in both 2025-q1 and 2025-q2") {
+		t.Errorf("Expected error for migration files with the same name in different directories, got %v", err)
+	}
+}
+
+// dirCountingFS counts how often the directories of an FS are read.
+type dirCountingFS struct {
+	files    fstest.MapFS
+	dirReads int
+}
+
+func (d *dirCountingFS) Open(name string) (fs.File, error) {
+	return d.files.Open(name)
+}
+
+func (d *dirCountingFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	d.dirReads++
+	return d.files.ReadDir(name)
+}
+
+func TestFSMigrationSourceRecursiveWalksOnlyWhenListing(t *testing.T) {
+	countingFS := &dirCountingFS{
+		files: fstest.MapFS{
+			"migrations/2025-q1/1_init.up.sql":   {Data: []byte("CREATE TABLE test_table1 (id integer not null primary key);")},
+			"migrations/2025-q1/1_init.down.sql": {Data: []byte("DROP TABLE test_table1;")},
+			"migrations/2025-q2/2_update.up.sql": {Data: []byte("ALTER TABLE test_table1 ADD COLUMN name text;")},
+		},
+	}
+
+	source := FSMigrationSource{
+		FS:        countingFS,
+		Dir:       "migrations",
+		Recursive: true,
+	}
+
+	files, err := source.ListMigrationFiles()
+	if err != nil {
+		t.Fatalf("Unexpected error while listing migration files: %s", err)
+	}
+
+	listingReads := countingFS.dirReads
+
+	for _, file := range files {
+		if _, err := source.GetMigrationFile(file); err != nil {
+			t.Fatalf("Unexpected error while getting migration file %s: %s", file, err)
+		}
+	}
+
+	if countingFS.dirReads != listingReads {
+		t.Errorf("Expected reading migration files not to walk the directory again, got %d directory reads after the listing", countingFS.dirReads-listingReads)
+	}
+}
+
+func TestMigrationCacheWithFSSources(t *testing.T) {
+	source := FSMigrationSource{
+		FS: fstest.MapFS{
+			"1_init.up.sql": {Data: []byte("CREATE TABLE test (id integer);")},
+		},
+	}
+
+	cache := NewMigrationCache()
+
+	// Sources passed as values are not cached, even if they hold fields that cannot be compared
+	for i := 0; i < 2; i++ {
+		if _, err := Migrations(source, Postgres, WithMigrationCache(cache)); err != nil {
+			t.Fatalf("Unexpected error in run %d: %s", i, err)
+		}
+	}
+
+	if len(cache.migrations) != 0 {
+		t.Errorf("Expected migrations of a source passed as a value not to be cached, got %d", len(cache.migrations))
+	}
+
+	for i := 0; i < 2; i++ {
+		if _, err := Migrations(&source, Postgres, WithMigrationCache(cache)); err != nil {
+			t.Fatalf("Unexpected error in run %d: %s", i, err)
+		}
+	}
+
+	if len(cache.migrations) != 1 || len(cache.sources) != 1 {
+		t.Errorf("Expected the migration of a source passed as a pointer to be cached once, got %d migrations of %d sources", len(cache.migrations), len(cache.sources))
+	}
+}
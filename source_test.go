@@ -1,6 +1,11 @@
 package migration
 
 import (
+	"context"
+	"database/sql"
+	"errors"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -32,3 +37,255 @@ func TestGolangMigrationSource(t *testing.T) {
 		t.Errorf("Applied %d migrations, but driver is showing %d applied.", applied, len(driver.applied))
 	}
 }
+
+func TestGolangMigrationSourceWithTxMigrations(t *testing.T) {
+	assetMigration := NewGolangMigrationSource()
+
+	called := false
+
+	assetMigration.AddMigration("1_init", Up, func() error {
+		called = true
+		return nil
+	})
+
+	assetMigration.AddTxMigration("2_update", Up, func(ctx context.Context, tx *sql.Tx) error {
+		return nil
+	})
+
+	assetMigration.AddTxMigration("2_update", Down, func(ctx context.Context, tx *sql.Tx) error {
+		return nil
+	})
+
+	migrations, err := getMigrations(assetMigration, newConfig(nil))
+	if err != nil {
+		t.Fatalf("Unexpected error while getting migrations: %s", err)
+	}
+	if len(migrations) != 2 {
+		t.Fatalf("Expected %d migrations, got %d", 2, len(migrations))
+	}
+
+	if migrations[0].UpFunc == nil || migrations[0].DownFunc != nil {
+		t.Fatal("Migrations added using AddMigration should have a function for each added direction")
+	}
+
+	if err := migrations[0].UpFunc(context.Background(), nil); err != nil || !called {
+		t.Error("Functions of migrations added using AddMigration should call the migration")
+	}
+
+	if migrations[1].UpFunc == nil || migrations[1].DownFunc == nil {
+		t.Error("Migrations added using AddTxMigration should have transaction functions")
+	}
+}
+
+func TestGolangMigrationSourceReplacesMigrations(t *testing.T) {
+	assetMigration := NewGolangMigrationSource()
+
+	assetMigration.AddMigration("1_init", Up, func() error {
+		return nil
+	})
+
+	assetMigration.AddTxMigration("1_init", Up, func(ctx context.Context, tx *sql.Tx) error {
+		return nil
+	})
+
+	files, err := assetMigration.ListMigrationFiles()
+	if err != nil {
+		t.Fatalf("Unexpected error while listing migration files: %s", err)
+	}
+
+	if !reflect.DeepEqual(files, []string{"1_init.up.go"}) {
+		t.Errorf("Expected a migration added twice to be listed once, got %v", files)
+	}
+
+	if assetMigration.GetMigration("1_init.up") != nil || assetMigration.GetTxMigration("1_init.up") == nil {
+		t.Error("Expected AddTxMigration to replace the migration added using AddMigration")
+	}
+}
+
+func TestCompositeMigrationSource(t *testing.T) {
+	goMigrations := NewGolangMigrationSource()
+
+	goMigrations.AddTxMigration("2_backfill", Up, func(ctx context.Context, tx *sql.Tx) error {
+		return nil
+	})
+
+	source := NewCompositeMigrationSource(
+		&MemoryMigrationSource{
+			Files: map[string]string{
+				"1_init.up.sql":       "CREATE TABLE test_table (id integer)",
+				"3_drop_col.up.sql":   "ALTER TABLE test_table DROP COLUMN id",
+				"10_cleanup.up.sql":   "",
+				"10_cleanup.down.sql": "",
+			},
+		},
+		goMigrations,
+	)
+
+	migrations, err := getMigrations(source, newConfig(nil))
+	if err != nil {
+		t.Fatalf("Unexpected error while getting migrations: %s", err)
+	}
+
+	var ids []string
+
+	for _, migration := range migrations {
+		ids = append(ids, migration.ID)
+	}
+
+	expected := []string{"1_init", "2_backfill", "3_drop_col", "10_cleanup"}
+
+	if !reflect.DeepEqual(ids, expected) {
+		t.Fatalf("Expected migrations %v, got %v", expected, ids)
+	}
+
+	if migrations[1].UpFunc == nil {
+		t.Error("Go migration in composite source should have a function")
+	}
+
+	if migrations[0].UpFunc != nil {
+		t.Error("SQL migration in composite source should not have a function")
+	}
+
+	driver := getMockDriver()
+
+	applied, err := Migrate(driver, source, Up, 0)
+	if err != nil {
+		t.Errorf("Unexpected error while performing composite migration: %s", err)
+	}
+	if applied != 4 {
+		t.Errorf("Expected %d migrations to be applied, %d applied.", 4, applied)
+	}
+}
+
+func TestCompositeMigrationSourceWithDuplicateMigrations(t *testing.T) {
+	goMigrations := NewGolangMigrationSource()
+
+	goMigrations.AddMigration("1_init", Up, func() error {
+		return nil
+	})
+
+	source := NewCompositeMigrationSource(
+		&MemoryMigrationSource{
+			Files: map[string]string{
+				"1_init.up.sql": "",
+			},
+		},
+		goMigrations,
+	)
+
+	if _, err := getMigrations(source, newConfig(nil)); err == nil {
+		t.Error("Expected error when a migration is defined in more than one source, but there was no error")
+	}
+
+	duplicateFiles := NewCompositeMigrationSource(
+		&MemoryMigrationSource{Files: map[string]string{"1_init.up.sql": ""}},
+		&MemoryMigrationSource{Files: map[string]string{"1_init.up.sql": ""}},
+	)
+
+	if _, err := duplicateFiles.ListMigrationFiles(); err == nil {
+		t.Error("Expected error when a file exists in more than one source, but there was no error")
+	}
+}
+
+func TestMigrationsWithIncludes(t *testing.T) {
+	source := NewCompositeMigrationSource(
+		NewGolangMigrationSource(),
+		&MemoryMigrationSource{
+			Files: map[string]string{
+				"1_init.up.sql":            "CREATE TABLE ${table} (id integer);\n-- +migration Include: shared/audit_trigger.sql",
+				"2_include_self.up.sql":    "-- +migration Include: 2_include_self.up.sql",
+				"shared/audit_trigger.sql": "CREATE TRIGGER ${table}_audit AFTER UPDATE ON ${table} FOR EACH ROW EXECUTE FUNCTION audit();",
+			},
+		},
+	)
+
+	cfg := newConfig([]Option{
+		WithVariables(map[string]string{"table": "test_table"}),
+	})
+
+	_, err := getMigrations(source, cfg)
+	if err == nil {
+		t.Error("Expected error for a migration including itself, but there was no error")
+	}
+
+	delete(source.sources[1].(*MemoryMigrationSource).Files, "2_include_self.up.sql")
+
+	migrations, err := getMigrations(source, cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error while getting migrations: %s", err)
+	}
+
+	expected := []string{"CREATE TABLE test_table (id integer);\nCREATE TRIGGER test_table_audit AFTER UPDATE ON test_table FOR EACH ROW EXECUTE FUNCTION audit();\n"}
+
+	if !reflect.DeepEqual(migrations[0].Up.Statements, expected) {
+		t.Errorf("Expected statements %q, got %q", expected, migrations[0].Up.Statements)
+	}
+}
+
+// listingSource counts the listings of a source and can fail them.
+type listingSource struct {
+	Source
+	listed int
+	err    error
+}
+
+func (c *listingSource) ListMigrationFiles() ([]string, error) {
+	c.listed++
+
+	if c.err != nil {
+		return nil, c.err
+	}
+
+	return c.Source.ListMigrationFiles()
+}
+
+func TestCompositeMigrationSourceListsSourcesOnce(t *testing.T) {
+	goMigrations := NewGolangMigrationSource()
+
+	goMigrations.AddTxMigration("2_backfill", Up, func(ctx context.Context, tx *sql.Tx) error {
+		return nil
+	})
+
+	sqlSource := &listingSource{
+		Source: &MemoryMigrationSource{
+			Files: map[string]string{
+				"1_init.up.sql":     "CREATE TABLE test_table (id integer)",
+				"3_drop_col.up.sql": "ALTER TABLE test_table DROP COLUMN id",
+			},
+		},
+	}
+	goSource := &listingSource{Source: goMigrations}
+
+	source := NewCompositeMigrationSource(sqlSource, goSource)
+
+	if _, err := getMigrations(source, newConfig(nil)); err != nil {
+		t.Fatalf("Unexpected error while getting migrations: %s", err)
+	}
+
+	if sqlSource.listed != 1 || goSource.listed != 1 {
+		t.Errorf("Expected each source to be listed once, got %d and %d", sqlSource.listed, goSource.listed)
+	}
+}
+
+func TestCompositeMigrationSourceReturnsSourceErrors(t *testing.T) {
+	goMigrations := NewGolangMigrationSource()
+
+	goMigrations.AddTxMigration("2_backfill", Up, func(ctx context.Context, tx *sql.Tx) error {
+		return nil
+	})
+
+	failing := &listingSource{
+		Source: &MemoryMigrationSource{},
+		err:    errors.New("source unavailable"),
+	}
+
+	source := NewCompositeMigrationSource(goMigrations, failing)
+
+	if _, err := source.goMigration("2_backfill.up.go"); err == nil || !strings.Contains(err.Error(), "source unavailable") {
+		t.Errorf("Expected the error of the failing source, got %v", err)
+	}
+
+	if _, err := source.GetMigrationFile("2_backfill.up.go"); err == nil || !strings.Contains(err.Error(), "source unavailable") {
+		t.Errorf("Expected the error of the failing source, got %v", err)
+	}
+}
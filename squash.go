@@ -0,0 +1,186 @@
+package migration
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/Boostport/migration/parser"
+)
+
+// SquashedMigration is a baseline migration replacing all migrations up to and including a cut-off migration.
+type SquashedMigration struct {
+	// ID is the ID of the baseline migration, which is the ID of the cut-off migration. Databases
+	// that already applied the cut-off migration will therefore not run the baseline.
+	ID string
+
+	// Up contains the statements of the up migrations of all replaced migrations.
+	Up string
+
+	// Down contains the statements of the down migrations of all replaced migrations in reverse order.
+	// It is empty if one of the replaced migrations does not have a down migration.
+	Down string
+
+	// Replaced is the list of IDs of the migrations replaced by the baseline, including the cut-off migration.
+	Replaced []string
+}
+
+// Squash consolidates all migrations up to and including the cut-off migration into a single baseline
+// migration. The parsed statements of the migrations are concatenated, so that they are executed exactly
+// as they were before. Migrations marked with NoTransaction cannot be squashed together with migrations running
+// within a transaction. Once the files of the baseline migration have been written, the files of the
+// replaced migrations can be removed. The options are used for reading the migrations, as they would be by
+// Migrate, so that templates and variables are rendered like they are when the migrations are run.
+func Squash(migrations Source, cutoff string, opts ...Option) (*SquashedMigration, error) {
+	m, err := getMigrations(migrations, newConfig(opts))
+	if err != nil {
+		return nil, err
+	}
+
+	index := -1
+
+	for i, migration := range m {
+		if migration.ID == cutoff {
+			index = i
+			break
+		}
+	}
+
+	if index == -1 {
+		return nil, fmt.Errorf("the cut-off migration %s does not exist", cutoff)
+	}
+
+	var (
+		up       []*parser.ParsedMigration
+		down     []*parser.ParsedMigration
+		replaced []string
+	)
+
+	hasDown := true
+
+	for _, migration := range m[:index+1] {
+		if migration.UpFunc != nil || migration.DownFunc != nil {
+			return nil, fmt.Errorf("migration %s is a Go migration and cannot be squashed", migration.ID)
+		}
+
+		if migration.Up == nil {
+			return nil, fmt.Errorf("migration %s does not have an up migration", migration.ID)
+		}
+
+		if migration.Down == nil {
+			hasDown = false
+		}
+
+		up = append(up, migration.Up)
+		down = append([]*parser.ParsedMigration{migration.Down}, down...)
+		replaced = append(replaced, migration.ID)
+	}
+
+	// A single NoTransaction directive would remove the transactions of all other migrations, so that a
+	// failure leaves the database half migrated.
+	if err := checkTransactionModes(m[:index+1], Up); err != nil {
+		return nil, err
+	}
+
+	if hasDown {
+		if err := checkTransactionModes(m[:index+1], Down); err != nil {
+			return nil, err
+		}
+	}
+
+	squashed := &SquashedMigration{
+		ID:       cutoff,
+		Replaced: replaced,
+	}
+
+	squashed.Up, err = squashStatements(up, true)
+	if err != nil {
+		return nil, err
+	}
+
+	if hasDown {
+		squashed.Down, err = squashStatements(down, false)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return squashed, nil
+}
+
+func squashStatements(migrations []*parser.ParsedMigration, baseline bool) (string, error) {
+	var b strings.Builder
+
+	if baseline {
+		b.WriteString("-- +migration Baseline\n")
+	}
+
+	// Squash refuses migrations with different transaction modes
+	if len(migrations) > 0 && !migrations[0].UseTransaction {
+		b.WriteString("-- +migration NoTransaction\n")
+	}
+
+	for _, migration := range migrations {
+		err := migration.ForEachStatement(func(statement string, _ parser.Position, options parser.StatementOptions) error {
+			if strings.TrimSpace(statement) == "" {
+				return nil
+			}
+
+			b.WriteString("\n")
+			writeStatementOptions(&b, options)
+			b.WriteString("-- +migration BeginStatement\n")
+			b.WriteString(strings.TrimSpace(statement))
+			b.WriteString("\n-- +migration EndStatement\n")
+
+			return nil
+		})
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return b.String(), nil
+}
+
+// checkTransactionModes returns an error if some of the migrations run within a transaction in the direction
+// while others are marked with NoTransaction.
+func checkTransactionModes(migrations []*Migration, direction Direction) error {
+	first := migrations[0]
+
+	for _, migration := range migrations[1:] {
+		if migration.parsed(direction).UseTransaction != first.parsed(direction).UseTransaction {
+			return fmt.Errorf("the %s migration of %s %s and the %s migration of %s %s, so they cannot be squashed into the same baseline", direction, first.ID, transactionMode(first.parsed(direction)), direction, migration.ID, transactionMode(migration.parsed(direction)))
+		}
+	}
+
+	return nil
+}
+
+func transactionMode(migration *parser.ParsedMigration) string {
+	if migration.UseTransaction {
+		return "runs within a transaction"
+	}
+
+	return "is marked with NoTransaction"
+}
+
+// writeStatementOptions writes the directives setting the options of a statement.
+func writeStatementOptions(b *strings.Builder, options parser.StatementOptions) {
+	if options.IgnoreError != nil {
+		b.WriteString("-- +migration IgnoreError: " + options.IgnoreError.String() + "\n")
+	}
+
+	if options.Retries > 0 {
+		b.WriteString("-- +migration Retry: " + strconv.Itoa(options.Retries))
+
+		if options.RetryDelay > 0 {
+			b.WriteString(" " + options.RetryDelay.String())
+		}
+
+		b.WriteString("\n")
+	}
+
+	if len(options.Allow) > 0 {
+		b.WriteString("-- +migration Allow: " + strings.Join(options.Allow, ", ") + "\n")
+	}
+}
@@ -0,0 +1,235 @@
+package migration
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Boostport/migration/parser"
+)
+
+func TestSquash(t *testing.T) {
+	memoryMigration := &MemoryMigrationSource{
+		Files: map[string]string{
+			"1_init.up.sql":           "CREATE TABLE test_table1 (id integer not null primary key);",
+			"1_init.down.sql":         "DROP TABLE test_table1;",
+			"2_update.up.sql":         "CREATE TABLE test_table2 (id integer);\n-- +migration Retry: 2 1s\nCREATE INDEX idx ON test_table2 (id);",
+			"2_update.down.sql":       "DROP TABLE test_table2;",
+			"3_add_column.up.sql":     "ALTER TABLE test_table1 ADD COLUMN name text;",
+			"3_add_column.down.sql":   "ALTER TABLE test_table1 DROP COLUMN name;",
+			"4_not_squashed.up.sql":   "DROP TABLE test_table2;",
+			"4_not_squashed.down.sql": "CREATE TABLE test_table2 (id integer);",
+		},
+	}
+
+	squashed, err := Squash(memoryMigration, "3_add_column")
+	if err != nil {
+		t.Fatalf("Unexpected error while squashing migrations: %s", err)
+	}
+
+	if squashed.ID != "3_add_column" {
+		t.Errorf("Expected baseline to have the ID of the cut-off migration, got %s", squashed.ID)
+	}
+
+	if !reflect.DeepEqual(squashed.Replaced, []string{"1_init", "2_update", "3_add_column"}) {
+		t.Errorf("Unexpected replaced migrations: %v", squashed.Replaced)
+	}
+
+	up, err := parser.Parse(strings.NewReader(squashed.Up))
+	if err != nil {
+		t.Fatalf("Unexpected error while parsing squashed up migration: %s", err)
+	}
+
+	if !up.Baseline || !up.UseTransaction {
+		t.Errorf("Expected squashed up migration to be a baseline within a transaction, got baseline %t and transaction %t", up.Baseline, up.UseTransaction)
+	}
+
+	expectedUp := []string{
+		"CREATE TABLE test_table1 (id integer not null primary key);\n",
+		"CREATE TABLE test_table2 (id integer);\n",
+		"CREATE INDEX idx ON test_table2 (id);\n",
+		"ALTER TABLE test_table1 ADD COLUMN name text;\n",
+	}
+
+	if !reflect.DeepEqual(up.Statements, expectedUp) {
+		t.Errorf("Expected squashed up statements %q, got %q", expectedUp, up.Statements)
+	}
+
+	if options := up.StatementOptions(2); options.Retries != 2 || options.RetryDelay != time.Second {
+		t.Errorf("Expected squashed statement to keep its Retry directive, got %+v", options)
+	}
+
+	down, err := parser.Parse(strings.NewReader(squashed.Down))
+	if err != nil {
+		t.Fatalf("Unexpected error while parsing squashed down migration: %s", err)
+	}
+
+	if down.Baseline {
+		t.Error("Squashed down migration should not be a baseline")
+	}
+
+	expectedDown := []string{
+		"ALTER TABLE test_table1 DROP COLUMN name;\n",
+		"DROP TABLE test_table2;\n",
+		"DROP TABLE test_table1;\n",
+	}
+
+	if !reflect.DeepEqual(down.Statements, expectedDown) {
+		t.Errorf("Expected squashed down statements %q, got %q", expectedDown, down.Statements)
+	}
+}
+
+func TestSquashWithOptions(t *testing.T) {
+	memoryMigration := &MemoryMigrationSource{
+		Files: map[string]string{
+			"1_init.up.sql":            "CREATE TABLE events (id integer);\nCREATE INDEX idx ON events (id);",
+			"1_init.down.sql":          "DROP TABLE events;",
+			"2_partitions.up.sql.tmpl": "{{range .Partitions}}CREATE TABLE events_{{.}} (id integer);\n{{end}}",
+			"2_partitions.down.sql":    "DROP TABLE events_{{not rendered}};",
+		},
+	}
+
+	// Templates are rendered using the template data, and files that are streamed are squashed as well
+	squashed, err := Squash(memoryMigration, "2_partitions", WithTemplateData(struct{ Partitions []string }{Partitions: []string{"2025", "2026"}}), WithStreaming())
+	if err != nil {
+		t.Fatalf("Unexpected error while squashing migrations: %s", err)
+	}
+
+	up, err := parser.Parse(strings.NewReader(squashed.Up))
+	if err != nil {
+		t.Fatalf("Unexpected error while parsing squashed up migration: %s", err)
+	}
+
+	expectedUp := []string{
+		"CREATE TABLE events (id integer);\n",
+		"CREATE INDEX idx ON events (id);\n",
+		"CREATE TABLE events_2025 (id integer);\nCREATE TABLE events_2026 (id integer);\n",
+	}
+
+	if !reflect.DeepEqual(up.Statements, expectedUp) {
+		t.Errorf("Expected squashed up statements %q, got %q", expectedUp, up.Statements)
+	}
+
+	if !strings.Contains(squashed.Down, "DROP TABLE events_{{not rendered}};") {
+		t.Errorf("Expected files without .tmpl not to be rendered, got down migration %q", squashed.Down)
+	}
+
+	if _, err := Squash(memoryMigration, "2_partitions"); err == nil {
+		t.Error("Expected error while squashing a template without its data, but there was no error")
+	}
+}
+
+func TestSquashWithMissingDownMigration(t *testing.T) {
+	memoryMigration := &MemoryMigrationSource{
+		Files: map[string]string{
+			"1_init.up.sql":   "CREATE TABLE test_table1 (id integer not null primary key);",
+			"2_update.up.sql": "CREATE TABLE test_table2 (id integer);",
+		},
+	}
+
+	squashed, err := Squash(memoryMigration, "2_update")
+	if err != nil {
+		t.Fatalf("Unexpected error while squashing migrations: %s", err)
+	}
+
+	if squashed.Down != "" {
+		t.Errorf("Expected no down migration when a replaced migration has no down migration, got %q", squashed.Down)
+	}
+
+	if _, err := Squash(memoryMigration, "3_missing"); err == nil {
+		t.Error("Expected error when squashing up to a migration that does not exist, but there was no error")
+	}
+}
+
+func TestSquashWithTransactionModes(t *testing.T) {
+	mixed := &MemoryMigrationSource{
+		Files: map[string]string{
+			"1_init.up.sql":       "CREATE TABLE test_table1 (id integer not null primary key);",
+			"2_index.up.sql":      "-- +migration NoTransaction\nCREATE INDEX CONCURRENTLY idx ON test_table1 (id);",
+			"3_add_column.up.sql": "ALTER TABLE test_table1 ADD COLUMN name text;",
+		},
+	}
+
+	_, err := Squash(mixed, "3_add_column")
+	if err == nil || !strings.Contains(err.Error(), "the up migration of 1_init runs within a transaction and the up migration of 2_index is marked with NoTransaction") {
+		t.Errorf("Expected error when squashing migrations with different transaction modes, got %v", err)
+	}
+
+	noTransaction := &MemoryMigrationSource{
+		Files: map[string]string{
+			"1_index.up.sql":   "-- +migration NoTransaction\nCREATE INDEX CONCURRENTLY idx1 ON test_table1 (id);",
+			"1_index.down.sql": "DROP INDEX idx1;",
+			"2_index.up.sql":   "-- +migration NoTransaction\nCREATE INDEX CONCURRENTLY idx2 ON test_table1 (name);",
+			"2_index.down.sql": "DROP INDEX idx2;",
+		},
+	}
+
+	squashed, err := Squash(noTransaction, "2_index")
+	if err != nil {
+		t.Fatalf("Unexpected error while squashing migrations marked with NoTransaction: %s", err)
+	}
+
+	if strings.Count(squashed.Up, "-- +migration NoTransaction") != 1 || strings.Contains(squashed.Down, "NoTransaction") {
+		t.Errorf("Expected only the squashed up migration to be marked with NoTransaction, got up:\n%s\ndown:\n%s", squashed.Up, squashed.Down)
+	}
+
+	up, err := parser.Parse(strings.NewReader(squashed.Up))
+	if err != nil {
+		t.Fatalf("Unexpected error while parsing squashed up migration: %s", err)
+	}
+
+	if up.UseTransaction || len(up.Statements) != 2 {
+		t.Errorf("Expected 2 statements without a transaction, got %d statements and transaction %t", len(up.Statements), up.UseTransaction)
+	}
+}
+
+func TestPlanMigrationsWithBaseline(t *testing.T) {
+	baseline := &Migration{
+		ID: "3_baseline",
+		Up: &parser.ParsedMigration{Baseline: true},
+	}
+
+	next := &Migration{
+		ID: "4_next",
+		Up: &parser.ParsedMigration{},
+	}
+
+	migrations := []*Migration{baseline, next}
+
+	planned, err := planMigrations(migrations, []string{}, Up, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error while planning migrations for an empty database: %s", err)
+	}
+	if len(planned) != 2 {
+		t.Errorf("Expected baseline and next migration to be planned for an empty database, got %d migrations", len(planned))
+	}
+
+	planned, err = planMigrations(migrations, []string{"1_init", "2_update", "4_next"}, Up, 0)
+	if err != nil {
+		t.Fatalf("Unexpected error while planning migrations for a database past the baseline: %s", err)
+	}
+	if len(planned) != 0 {
+		t.Errorf("Expected baseline not to be re-run for a database past the baseline, got %d migrations", len(planned))
+	}
+
+	_, err = planMigrations(migrations, []string{"1_init"}, Up, 0)
+	if err == nil {
+		t.Error("Expected error while planning migrations for a database before the baseline, but there was no error")
+	}
+
+	// Databases before the baseline can still be migrated down using the replaced migrations
+	initial := &Migration{
+		ID:   "1_init",
+		Up:   &parser.ParsedMigration{},
+		Down: &parser.ParsedMigration{},
+	}
+
+	planned, err = planMigrations([]*Migration{initial, baseline, next}, []string{"1_init"}, Down, 1)
+	if err != nil {
+		t.Fatalf("Unexpected error while planning down migrations for a database before the baseline: %s", err)
+	}
+	if len(planned) != 1 || planned[0].ID != "1_init" || planned[0].Direction != Down {
+		t.Errorf("Expected 1_init to be migrated down, got %d migrations", len(planned))
+	}
+}
@@ -0,0 +1,48 @@
+package migration
+
+import (
+	"context"
+	"time"
+
+	"github.com/Boostport/migration/parser"
+)
+
+// ExecStatement should be called by drivers to execute a statement according to the options set by its
+// directives. If exec fails, it is called again as many times as the Retry directive of the statement allows.
+// Errors matching the IgnoreError directive of the statement are ignored.
+func ExecStatement(ctx context.Context, options parser.StatementOptions, exec func(ctx context.Context) error) error {
+	for retry := 0; ; retry++ {
+		err := exec(ctx)
+		if err == nil {
+			return nil
+		}
+
+		if options.IgnoreError != nil && options.IgnoreError.MatchString(err.Error()) {
+			logPrintf("Ignoring error matching %q: %s", options.IgnoreError, err)
+			return nil
+		}
+
+		if retry == options.Retries {
+			return err
+		}
+
+		logPrintf("Retrying statement after error: %s", err)
+
+		if err := wait(ctx, options.RetryDelay); err != nil {
+			return err
+		}
+	}
+}
+
+// wait waits for the delay to pass or the context to be done.
+func wait(ctx context.Context, delay time.Duration) error {
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,58 @@
+package migration
+
+import (
+	"context"
+	"errors"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/Boostport/migration/parser"
+)
+
+func TestExecStatement(t *testing.T) {
+	tests := map[string]struct {
+		options  parser.StatementOptions
+		failures int
+		calls    int
+		fails    bool
+	}{
+		"no options":             {parser.StatementOptions{}, 1, 1, true},
+		"retry until success":    {parser.StatementOptions{Retries: 3}, 2, 3, false},
+		"retries exhausted":      {parser.StatementOptions{Retries: 2}, 5, 3, true},
+		"ignored error":          {parser.StatementOptions{IgnoreError: regexp.MustCompile("does not exist")}, 1, 1, false},
+		"ignored error on retry": {parser.StatementOptions{IgnoreError: regexp.MustCompile("does not exist"), Retries: 1}, 1, 1, false},
+	}
+
+	for name, test := range tests {
+		calls := 0
+
+		err := ExecStatement(context.Background(), test.options, func(context.Context) error {
+			calls++
+
+			if calls <= test.failures {
+				return errors.New(`index "legacy_index" does not exist`)
+			}
+
+			return nil
+		})
+
+		if (err != nil) != test.fails {
+			t.Errorf("%s: unexpected error %v", name, err)
+		}
+
+		if calls != test.calls {
+			t.Errorf("%s: expected %d calls, got %d", name, test.calls, calls)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	err := ExecStatement(ctx, parser.StatementOptions{Retries: 3, RetryDelay: time.Second}, func(context.Context) error {
+		return errors.New("timeout")
+	})
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("Expected waiting for a retry to be canceled, got %v", err)
+	}
+}
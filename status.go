@@ -0,0 +1,81 @@
+package migration
+
+import "sort"
+
+// MigrationStatus reports whether a migration is applied to the database, along with the metadata set by
+// the directives in its header, such as Description.
+type MigrationStatus struct {
+	ID       string
+	Applied  bool
+	Metadata map[string]string
+
+	// Missing is set for versions applied to the database that are not in the source.
+	Missing bool
+}
+
+// Status reports the status of each migration of the source, and of the versions applied to the database that
+// are not in the source, ordered by ID. The metadata of a migration is read from its up migration, or from
+// its down migration if it does not have one. Unlike Migrate, Status does not close the driver.
+func Status(driver Driver, migrations Source, opts ...Option) ([]*MigrationStatus, error) {
+	cfg := newConfig(opts)
+
+	if d, ok := driver.(DialectDriver); ok {
+		cfg.dialect = d.Dialect()
+	}
+
+	loaded, err := getMigrations(migrations, cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	versions, err := driver.Versions()
+	if err != nil {
+		return nil, err
+	}
+
+	applied := map[string]bool{}
+
+	for _, version := range versions {
+		applied[version] = true
+	}
+
+	all := byID{}
+	statuses := map[string]*MigrationStatus{}
+
+	for _, migration := range loaded {
+		status := &MigrationStatus{
+			ID:      migration.ID,
+			Applied: applied[migration.ID],
+		}
+
+		if parsed := migration.parsed(Up); parsed != nil {
+			status.Metadata = parsed.Metadata
+		} else if parsed := migration.parsed(Down); parsed != nil {
+			status.Metadata = parsed.Metadata
+		}
+
+		all = append(all, migration)
+		statuses[migration.ID] = status
+	}
+
+	for _, version := range versions {
+		if _, ok := statuses[version]; !ok {
+			all = append(all, &Migration{ID: version})
+			statuses[version] = &MigrationStatus{
+				ID:      version,
+				Applied: true,
+				Missing: true,
+			}
+		}
+	}
+
+	sort.Stable(all)
+
+	result := make([]*MigrationStatus, len(all))
+
+	for i, migration := range all {
+		result[i] = statuses[migration.ID]
+	}
+
+	return result, nil
+}
@@ -0,0 +1,42 @@
+package migration
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestStatus(t *testing.T) {
+	memoryMigration := &MemoryMigrationSource{
+		Files: map[string]string{
+			"1_init.up.sql":      "-- +migration Description: Add the test table\nCREATE TABLE test (id integer);",
+			"1_init.down.sql":    "DROP TABLE test;",
+			"3_index.sql":        "-- +migration Description: Index the test table\n-- +migration Up\nCREATE INDEX idx ON test (id);\n-- +migration Down\nDROP INDEX idx;\n",
+			"4_cleanup.down.sql": "-- +migration Owner: data team\nDELETE FROM test;",
+		},
+	}
+
+	driver := getMockDriver()
+	driver.applied = []string{"1_init", "2_removed"}
+
+	statuses, err := Status(driver, memoryMigration, WithDirectives("Owner"))
+	if err != nil {
+		t.Fatalf("Unexpected error while getting the status: %s", err)
+	}
+
+	expected := []MigrationStatus{
+		{ID: "1_init", Applied: true, Metadata: map[string]string{"Description": "Add the test table"}},
+		{ID: "2_removed", Applied: true, Missing: true},
+		{ID: "3_index", Metadata: map[string]string{"Description": "Index the test table"}},
+		{ID: "4_cleanup", Metadata: map[string]string{"Owner": "data team"}},
+	}
+
+	var got []MigrationStatus
+
+	for _, status := range statuses {
+		got = append(got, *status)
+	}
+
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected statuses %+v, got %+v", expected, got)
+	}
+}
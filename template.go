@@ -0,0 +1,40 @@
+package migration
+
+import (
+	"strings"
+	"text/template"
+)
+
+const templateExtension = ".tmpl"
+
+// renderTemplate renders the contents of a migration file ending with .tmpl using text/template. The
+// template is executed with the data of the run. In addition to the functions of the run, templates can
+// call dialect to get the dialect of the driver.
+func renderTemplate(file, contents string, cfg *config) (string, error) {
+	if !strings.HasSuffix(file, templateExtension) {
+		return contents, nil
+	}
+
+	funcs := template.FuncMap{
+		"dialect": func() Dialect {
+			return cfg.dialect
+		},
+	}
+
+	for name, f := range cfg.templateFuncs {
+		funcs[name] = f
+	}
+
+	tmpl, err := template.New(file).Funcs(funcs).Option("missingkey=error").Parse(contents)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+
+	if err := tmpl.Execute(&b, cfg.templateData); err != nil {
+		return "", err
+	}
+
+	return b.String(), nil
+}
@@ -0,0 +1,54 @@
+package migration
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestMigrationsWithTemplates(t *testing.T) {
+	memoryMigration := &MemoryMigrationSource{
+		Files: map[string]string{
+			"1_init.up.sql.tmpl": `{{range $i := seq .Partitions}}CREATE TABLE events_{{$i}} (id integer);
+{{end}}{{if eq dialect "postgres"}}CREATE INDEX CONCURRENTLY idx ON events_0 (id);{{end}}`,
+			"1_init.down.sql": "DROP TABLE {{not rendered}};",
+		},
+	}
+
+	seq := func(n int) []int {
+		s := make([]int, n)
+		for i := range s {
+			s[i] = i
+		}
+		return s
+	}
+
+	cfg := newConfig([]Option{
+		WithTemplateData(struct{ Partitions int }{Partitions: 2}),
+		WithTemplateFuncs(template.FuncMap{"seq": seq}),
+	})
+	cfg.dialect = Postgres
+
+	migrations, err := getMigrations(memoryMigration, cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error while getting migrations: %s", err)
+	}
+
+	expectedUp := []string{"CREATE TABLE events_0 (id integer);\nCREATE TABLE events_1 (id integer);\nCREATE INDEX CONCURRENTLY idx ON events_0 (id);"}
+
+	if !reflect.DeepEqual(migrations[0].Up.Statements, expectedUp) {
+		t.Errorf("Expected up statements %q, got %q", expectedUp, migrations[0].Up.Statements)
+	}
+
+	expectedDown := []string{"DROP TABLE {{not rendered}};"}
+
+	if !reflect.DeepEqual(migrations[0].Down.Statements, expectedDown) {
+		t.Errorf("Expected down statements %q, got %q", expectedDown, migrations[0].Down.Statements)
+	}
+
+	_, err = getMigrations(memoryMigration, newConfig(nil))
+	if err == nil || !strings.Contains(err.Error(), "Error rendering migration 1_init") {
+		t.Errorf("Expected error for a template calling an undefined function, got %v", err)
+	}
+}
@@ -0,0 +1,71 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+)
+
+// Tracer is used to instrument migration runs. Each Start function returns a context that is passed down to the
+// next level (run, migration and statement) and a function that is called once that level has finished.
+type Tracer interface {
+	// StartRun is called when a run is started.
+	StartRun(ctx context.Context, direction Direction) (context.Context, func(applied int, err error))
+
+	// StartMigration is called before a planned migration is handed to the driver.
+	StartMigration(ctx context.Context, migration *PlannedMigration) (context.Context, func(err error))
+
+	// StartStatement is called by drivers before executing a statement of a migration.
+	StartStatement(ctx context.Context, migration *PlannedMigration, statement string) (context.Context, func(rowsAffected int64, err error))
+}
+
+type tracerContextKey struct{}
+
+func contextWithTracer(ctx context.Context, tracer Tracer) context.Context {
+	return context.WithValue(ctx, tracerContextKey{}, tracer)
+}
+
+func tracerFromContext(ctx context.Context) Tracer {
+	tracer, _ := ctx.Value(tracerContextKey{}).(Tracer)
+	return tracer
+}
+
+func startRun(ctx context.Context, direction Direction) (context.Context, func(applied int, err error)) {
+	tracer := tracerFromContext(ctx)
+	if tracer == nil {
+		return ctx, func(int, error) {}
+	}
+
+	return tracer.StartRun(ctx, direction)
+}
+
+func startMigration(ctx context.Context, migration *PlannedMigration) (context.Context, func(err error)) {
+	tracer := tracerFromContext(ctx)
+	if tracer == nil {
+		return ctx, func(error) {}
+	}
+
+	return tracer.StartMigration(ctx, migration)
+}
+
+// TraceStatement should be called by drivers before executing a statement. If the run has a Tracer,
+// the statement is reported to it. The returned function must be called with the result of the statement.
+func TraceStatement(ctx context.Context, migration *PlannedMigration, statement string) (context.Context, func(result sql.Result, err error)) {
+	tracer := tracerFromContext(ctx)
+	if tracer == nil {
+		return ctx, func(sql.Result, error) {}
+	}
+
+	ctx, end := tracer.StartStatement(ctx, migration, statement)
+
+	return ctx, func(result sql.Result, err error) {
+		rowsAffected := int64(-1)
+
+		if result != nil {
+			if affected, err := result.RowsAffected(); err == nil {
+				rowsAffected = affected
+			}
+		}
+
+		end(rowsAffected, err)
+	}
+}
@@ -0,0 +1,83 @@
+package migration
+
+import (
+	"context"
+	"reflect"
+	"testing"
+)
+
+type recordingTracer struct {
+	events []string
+}
+
+func (r *recordingTracer) StartRun(ctx context.Context, direction Direction) (context.Context, func(applied int, err error)) {
+	r.events = append(r.events, "start run "+direction.String())
+
+	return ctx, func(applied int, err error) {
+		r.events = append(r.events, "end run")
+	}
+}
+
+func (r *recordingTracer) StartMigration(ctx context.Context, migration *PlannedMigration) (context.Context, func(err error)) {
+	r.events = append(r.events, "start migration "+migration.ID)
+
+	return ctx, func(err error) {
+		if err != nil {
+			r.events = append(r.events, "end migration "+migration.ID+" with error")
+			return
+		}
+		r.events = append(r.events, "end migration "+migration.ID)
+	}
+}
+
+func (r *recordingTracer) StartStatement(ctx context.Context, migration *PlannedMigration, statement string) (context.Context, func(rowsAffected int64, err error)) {
+	r.events = append(r.events, "start statement "+statement)
+
+	return ctx, func(rowsAffected int64, err error) {
+		r.events = append(r.events, "end statement "+statement)
+	}
+}
+
+func TestTracer(t *testing.T) {
+	memoryMigration := &MemoryMigrationSource{
+		Files: map[string]string{
+			"1_init.up.sql":   "init",
+			"2_update.up.sql": "error",
+		},
+	}
+
+	tracer := &recordingTracer{}
+
+	_, err := Migrate(getMockDriver(), memoryMigration, Up, 0, WithTracer(tracer))
+	if err == nil {
+		t.Error("Expected error while running migration, but there was no error")
+	}
+
+	expected := []string{
+		"start run up",
+		"start migration 1_init",
+		"start statement init",
+		"end statement init",
+		"end migration 1_init",
+		"start migration 2_update",
+		"start statement error",
+		"end statement error",
+		"end migration 2_update with error",
+		"end run",
+	}
+
+	if !reflect.DeepEqual(tracer.events, expected) {
+		t.Errorf("Expected traced events %v, got %v", expected, tracer.events)
+	}
+}
+
+func TestTraceStatementWithoutTracer(t *testing.T) {
+	ctx := context.Background()
+
+	tracedCtx, end := TraceStatement(ctx, &PlannedMigration{Migration: &Migration{ID: "1_init"}}, "statement")
+	if tracedCtx != ctx {
+		t.Error("Expected context to be unchanged when the run has no tracer")
+	}
+
+	end(nil, nil)
+}
@@ -0,0 +1,111 @@
+// Package opentelemetry traces migration runs using OpenTelemetry.
+package opentelemetry
+
+import (
+	"context"
+
+	m "github.com/Boostport/migration"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/Boostport/migration/tracing/opentelemetry"
+
+// Attribute keys set on the spans created by the Tracer.
+const (
+	DirectionKey      = attribute.Key("migration.direction")
+	AppliedKey        = attribute.Key("migration.applied")
+	IDKey             = attribute.Key("migration.id")
+	UseTransactionKey = attribute.Key("migration.use_transaction")
+	DescriptionKey    = attribute.Key("migration.description")
+	StatementKey      = attribute.Key("db.statement")
+	RowsAffectedKey   = attribute.Key("db.rows_affected")
+)
+
+// Tracer is the OpenTelemetry migration.Tracer implementation
+type Tracer struct {
+	tracer trace.Tracer
+}
+
+// New creates a new Tracer using the given TracerProvider. If provider is nil, the global
+// TracerProvider is used.
+func New(provider trace.TracerProvider) m.Tracer {
+	if provider == nil {
+		provider = otel.GetTracerProvider()
+	}
+
+	return &Tracer{
+		tracer: provider.Tracer(instrumentationName),
+	}
+}
+
+// StartRun starts a span for a migration run.
+func (t *Tracer) StartRun(ctx context.Context, direction m.Direction) (context.Context, func(applied int, err error)) {
+	ctx, span := t.tracer.Start(ctx, "migration.run", trace.WithAttributes(
+		DirectionKey.String(direction.String()),
+	))
+
+	return ctx, func(applied int, err error) {
+		span.SetAttributes(AppliedKey.Int(applied))
+		end(span, err)
+	}
+}
+
+// StartMigration starts a span for a planned migration.
+func (t *Tracer) StartMigration(ctx context.Context, migration *m.PlannedMigration) (context.Context, func(err error)) {
+	ctx, span := t.tracer.Start(ctx, "migration.migrate", trace.WithAttributes(
+		migrationAttributes(migration)...,
+	))
+
+	return ctx, func(err error) {
+		end(span, err)
+	}
+}
+
+// StartStatement starts a span for a statement executed by the driver.
+func (t *Tracer) StartStatement(ctx context.Context, migration *m.PlannedMigration, statement string) (context.Context, func(rowsAffected int64, err error)) {
+	attributes := append(migrationAttributes(migration), StatementKey.String(statement))
+
+	ctx, span := t.tracer.Start(ctx, "migration.statement", trace.WithAttributes(attributes...), trace.WithSpanKind(trace.SpanKindClient))
+
+	return ctx, func(rowsAffected int64, err error) {
+		if rowsAffected >= 0 {
+			span.SetAttributes(RowsAffectedKey.Int64(rowsAffected))
+		}
+		end(span, err)
+	}
+}
+
+func migrationAttributes(migration *m.PlannedMigration) []attribute.KeyValue {
+	attributes := []attribute.KeyValue{
+		IDKey.String(migration.ID),
+		DirectionKey.String(migration.Direction.String()),
+	}
+
+	parsed := migration.Up
+
+	if migration.Direction == m.Down {
+		parsed = migration.Down
+	}
+
+	if parsed != nil {
+		attributes = append(attributes, UseTransactionKey.Bool(parsed.UseTransaction))
+
+		if description, ok := parsed.Metadata["Description"]; ok {
+			attributes = append(attributes, DescriptionKey.String(description))
+		}
+	}
+
+	return attributes
+}
+
+func end(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+
+	span.End()
+}
@@ -0,0 +1,140 @@
+package opentelemetry
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/Boostport/migration"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+type result int64
+
+func (r result) LastInsertId() (int64, error) {
+	return 0, nil
+}
+
+func (r result) RowsAffected() (int64, error) {
+	return int64(r), nil
+}
+
+type stubDriver struct {
+	applied []string
+}
+
+func (s *stubDriver) Close() error {
+	return nil
+}
+
+func (s *stubDriver) Migrate(migration *migration.PlannedMigration) error {
+	return s.MigrateContext(context.Background(), migration)
+}
+
+func (s *stubDriver) MigrateContext(ctx context.Context, plannedMigration *migration.PlannedMigration) error {
+	for _, statement := range plannedMigration.Up.Statements {
+		_, end := migration.TraceStatement(ctx, plannedMigration, statement)
+
+		if strings.Contains(statement, "error") {
+			err := context.DeadlineExceeded
+			end(nil, err)
+			return err
+		}
+
+		end(result(3), nil)
+	}
+
+	s.applied = append(s.applied, plannedMigration.ID)
+
+	return nil
+}
+
+func (s *stubDriver) Versions() ([]string, error) {
+	return s.applied, nil
+}
+
+func TestTracer(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	source := &migration.MemoryMigrationSource{
+		Files: map[string]string{
+			"1_init.up.sql":   "-- +migration Description: Create the test table\nCREATE TABLE test_table (id integer not null primary key);",
+			"2_update.up.sql": "-- +migration NoTransaction\nINSERT INTO test_table (id) VALUES (1);\nINSERT INTO test_table (id) VALUES (2);",
+			"3_error.up.sql":  "error",
+		},
+	}
+
+	applied, err := migration.Migrate(&stubDriver{}, source, migration.Up, 0, migration.WithTracer(New(provider)))
+	if err == nil {
+		t.Error("Expected error while running migration, but there was no error")
+	}
+	if applied != 2 {
+		t.Errorf("Expected %d migrations to be applied, %d applied.", 2, applied)
+	}
+
+	spans := recorder.Ended()
+
+	var names []string
+
+	for _, span := range spans {
+		names = append(names, span.Name())
+	}
+
+	expected := []string{
+		"migration.statement",
+		"migration.migrate",
+		"migration.statement",
+		"migration.statement",
+		"migration.migrate",
+		"migration.statement",
+		"migration.migrate",
+		"migration.run",
+	}
+
+	if strings.Join(names, ",") != strings.Join(expected, ",") {
+		t.Fatalf("Expected spans %v, got %v", expected, names)
+	}
+
+	run := spans[len(spans)-1]
+
+	for _, span := range spans[:len(spans)-1] {
+		if span.Parent().SpanID() == run.SpanContext().SpanID() && span.Name() != "migration.migrate" {
+			t.Errorf("Span %s should not be a direct child of the run span", span.Name())
+		}
+	}
+
+	if spans[0].Parent().SpanID() != spans[1].SpanContext().SpanID() {
+		t.Error("Statement span should be a child of the migration span")
+	}
+
+	assertAttribute(t, spans[1].Attributes(), IDKey, attribute.StringValue("1_init"))
+	assertAttribute(t, spans[1].Attributes(), DirectionKey, attribute.StringValue("up"))
+	assertAttribute(t, spans[1].Attributes(), UseTransactionKey, attribute.BoolValue(true))
+	assertAttribute(t, spans[1].Attributes(), DescriptionKey, attribute.StringValue("Create the test table"))
+	assertAttribute(t, spans[2].Attributes(), UseTransactionKey, attribute.BoolValue(false))
+	assertAttribute(t, spans[2].Attributes(), RowsAffectedKey, attribute.Int64Value(3))
+	assertAttribute(t, run.Attributes(), AppliedKey, attribute.IntValue(2))
+
+	if spans[5].Status().Code != codes.Error || spans[6].Status().Code != codes.Error || run.Status().Code != codes.Error {
+		t.Error("Failing statement, migration and run spans should have an error status")
+	}
+}
+
+func assertAttribute(t *testing.T, attributes []attribute.KeyValue, key attribute.Key, expected attribute.Value) {
+	t.Helper()
+
+	for _, attr := range attributes {
+		if attr.Key == key {
+			if attr.Value != expected {
+				t.Errorf("Expected attribute %s to be %v, got %v", key, expected.Emit(), attr.Value.Emit())
+			}
+			return
+		}
+	}
+
+	t.Errorf("Attribute %s was not set", key)
+}
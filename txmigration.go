@@ -0,0 +1,38 @@
+package migration
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+)
+
+// ExecTxMigration should be called by SQL drivers that do not otherwise run migrations within transactions to
+// execute a Go migration. It runs the function and updateVersion, which receives the ID of the migration as its
+// only argument, within a single transaction of db, so that the changes made by the migration and the recorded
+// version are committed atomically.
+func ExecTxMigration(ctx context.Context, db *sql.DB, migration *PlannedMigration, migrationFunc TxMigrationFunc, updateVersion string) (err error) {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer func() {
+		if err != nil {
+			if errRb := tx.Rollback(); errRb != nil {
+				err = fmt.Errorf("error rolling back: %s\n%s", errRb, err)
+			}
+			return
+		}
+		err = tx.Commit()
+	}()
+
+	if err = migrationFunc(ctx, tx); err != nil {
+		return fmt.Errorf("error executing golang migration: %s", err)
+	}
+
+	if _, err = tx.ExecContext(ctx, updateVersion, migration.ID); err != nil {
+		return fmt.Errorf("error updating migration versions: %s", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,91 @@
+package migration
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+)
+
+var variableRegex = regexp.MustCompile(`\$?\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+
+// substituteVariables replaces ${name} placeholders in the contents of a migration with the values of
+// the variables of the run. $${name} is replaced with a literal ${name}. Contents are only substituted
+// if variables were supplied to the run.
+func substituteVariables(contents string, cfg *config) (string, error) {
+	if cfg.variables == nil && cfg.variableLookup == nil {
+		return contents, nil
+	}
+
+	var err error
+
+	substituted := variableRegex.ReplaceAllStringFunc(contents, func(placeholder string) string {
+		if placeholder[1] == '$' {
+			return placeholder[1:]
+		}
+
+		name := variableRegex.FindStringSubmatch(placeholder)[1]
+
+		if value, ok := cfg.variables[name]; ok {
+			return value
+		}
+
+		if cfg.variableLookup != nil {
+			if value, ok := cfg.variableLookup(name); ok {
+				return value
+			}
+		}
+
+		if err == nil {
+			err = fmt.Errorf("undefined variable ${%s}", name)
+		}
+
+		return placeholder
+	})
+
+	return substituted, err
+}
+
+// variableReader substitutes the variables of a migration line by line while it is read.
+type variableReader struct {
+	r   *bufio.Reader
+	cfg *config
+	buf string
+	err error
+}
+
+func newVariableReader(r io.Reader, cfg *config) io.Reader {
+	if cfg.variables == nil && cfg.variableLookup == nil {
+		return r
+	}
+
+	return &variableReader{
+		r:   bufio.NewReader(r),
+		cfg: cfg,
+	}
+}
+
+func (v *variableReader) Read(p []byte) (int, error) {
+	for v.buf == "" {
+		if v.err != nil {
+			return 0, v.err
+		}
+
+		var line string
+
+		line, v.err = v.r.ReadString('\n')
+
+		substituted, err := substituteVariables(line, v.cfg)
+		if err != nil {
+			v.err = err
+			return 0, err
+		}
+
+		v.buf = substituted
+	}
+
+	n := copy(p, v.buf)
+	v.buf = v.buf[n:]
+
+	return n, nil
+}
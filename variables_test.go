@@ -0,0 +1,61 @@
+package migration
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMigrationsWithVariables(t *testing.T) {
+	memoryMigration := &MemoryMigrationSource{
+		Files: map[string]string{
+			"1_init.up.sql":   "CREATE TABLE ${schema}.test_table1 (id integer) TABLESPACE ${tablespace};\nGRANT SELECT ON ${schema}.test_table1 TO $${role};",
+			"1_init.down.sql": "DROP TABLE ${schema}.test_table1;",
+		},
+	}
+
+	lookup := func(name string) (string, bool) {
+		if name == "tablespace" {
+			return "fast", true
+		}
+		return "", false
+	}
+
+	cfg := newConfig([]Option{
+		WithVariables(map[string]string{"schema": "tenant1"}),
+		WithVariableLookup(lookup),
+	})
+
+	migrations, err := getMigrations(memoryMigration, cfg)
+	if err != nil {
+		t.Fatalf("Unexpected error while getting migrations: %s", err)
+	}
+
+	expectedUp := []string{"CREATE TABLE tenant1.test_table1 (id integer) TABLESPACE fast;\nGRANT SELECT ON tenant1.test_table1 TO ${role};"}
+
+	if !reflect.DeepEqual(migrations[0].Up.Statements, expectedUp) {
+		t.Errorf("Expected up statements %q, got %q", expectedUp, migrations[0].Up.Statements)
+	}
+
+	expectedDown := []string{"DROP TABLE tenant1.test_table1;"}
+
+	if !reflect.DeepEqual(migrations[0].Down.Statements, expectedDown) {
+		t.Errorf("Expected down statements %q, got %q", expectedDown, migrations[0].Down.Statements)
+	}
+
+	cfg = newConfig([]Option{
+		WithVariables(map[string]string{"schema": "tenant1"}),
+	})
+
+	if _, err := getMigrations(memoryMigration, cfg); err == nil {
+		t.Error("Expected error for an undefined variable, but there was no error")
+	}
+
+	migrations, err = getMigrations(memoryMigration, newConfig(nil))
+	if err != nil {
+		t.Fatalf("Unexpected error while getting migrations without variables: %s", err)
+	}
+
+	if migrations[0].Down.Statements[0] != "DROP TABLE ${schema}.test_table1;" {
+		t.Errorf("Expected placeholders to be left alone without variables, got %q", migrations[0].Down.Statements[0])
+	}
+}